@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+func allow(w io.Writer, args ...string) {
+	args, opts, err := parseFlags(args, "for", "budget")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	now := time.Now()
+
+	if opts.Has("budget") {
+		budget, err := strconv.Atoi(opts["budget"])
+		if err != nil || budget < 1 {
+			fmt.Fprintf(w, "invalid budget %q, expected a positive number\n", opts["budget"])
+			return
+		}
+		if err := restrictions.CheckLock(c, now); err != nil {
+			fmt.Fprintf(w, "%v, refusing to change the budget\n", err)
+			return
+		}
+		c.AllowanceBudget = budget
+		if err := restrictions.WriteConfig(c); err != nil {
+			fmt.Fprintf(w, "failed to update config: %v\n", err)
+			return
+		}
+		fmt.Fprintf(w, "daily allowance budget set to %v\n", budget)
+		return
+	}
+
+	if len(args) < 1 {
+		fmt.Fprintf(w, "no <type> specified\n")
+		return
+	}
+
+	matched, err := restrictions.ParseType(args[0])
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	if len(args) < 2 {
+		fmt.Fprintf(w, "no <item> specified\n")
+		return
+	}
+	item := strings.TrimSpace(args[1])
+
+	if !opts.Has("for") {
+		fmt.Fprintf(w, "no --for <duration> specified\n")
+		return
+	}
+	d, err := time.ParseDuration(opts["for"])
+	if err != nil || d <= 0 {
+		fmt.Fprintf(w, "invalid duration %q, expected a positive duration such as 15m\n", opts["for"])
+		return
+	}
+
+	if err := restrictions.CheckLock(c, now); err != nil {
+		fmt.Fprintf(w, "%v, refusing to allow %q\n", err, item)
+		return
+	}
+
+	if c.LastCommited == nil || !slices.Contains(c.LastCommited.Active(now)[matched].Items(), item) {
+		fmt.Fprintf(w, "%s %q is not currently enforced\n", matched, item)
+		return
+	}
+
+	a := restrictions.Allowance{Type: matched, Item: item, From: now, Until: now.Add(d)}
+	if err := c.Allow(a); err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	done, err := restrictions.MarkApplying()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer done()
+
+	c.LastCommited.Allowances = append(c.LastCommited.ActiveAllowances(now), a)
+	if _, err := enforce(c.LastCommited, matched, now); err != nil {
+		fmt.Fprintf(w, "failed to lift the restriction: %v\n", err)
+		return
+	}
+
+	if err := restrictions.WriteConfig(c); err != nil {
+		fmt.Fprintf(w, "failed to update config: %v\n", err)
+		return
+	}
+
+	if err := restrictions.Audit(restrictions.AuditEntry{
+		Time:   now,
+		Action: "allow",
+		Type:   matched.String(),
+		Item:   item,
+		Until:  a.Until,
+	}); err != nil {
+		fmt.Fprintf(w, "failed to record the allowance in the audit trail %s: %v\n", restrictions.AuditPath(), err)
+	}
+
+	fmt.Fprintf(w, "%s %q allowed until %s, the restriction is restored afterwards by the daemon or the next commit\n", matched, item, a.Until.Format(time.DateTime))
+}
@@ -8,13 +8,12 @@ import (
 	"io"
 	"maps"
 	"os"
-	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Despire/dnd/restrictions"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
 var Usage = `dnd (short for do not disturb) is a program to block access to selected
@@ -24,67 +23,180 @@ For the restrictions either added/deleted to take effect the ':commit' subcommon
 
 subcommands:
 	:help
-	:commit                 Commits the configured restrictions. Requires sudo.
-	:add    <type> <args>   Adds a new restriction.
+	:commit [type]          Commits the configured restrictions, of all types or only
+	                        of the given type. The changes of /etc/hosts are made by
+	                        a privileged helper run with sudo or pkexec.
+	        [--dry-run]       Only prints the pending changes. Exits with 0 if there are
+	                          none, 2 if there are and 1 on failure.
+	        [--yes]           Commits without asking for confirmation.
+	        [--output <f>]    Prints the pending changes of a dry run as json, yaml or table.
+	        [--explain]       Commits to a copy of the system, printing the commands, i.e.
+	                          systemctl, launchctl or the helper run with sudo, a commit would
+	                          run with the real paths. Exits as --dry-run.
+	:add    <type> <args>   Adds a new restriction. Domains are validated and normalized,
+	                        internationalized names are converted to punycode.
+	        [-f <file>]       Reads the domains from the file, or stdin for '-', one per line.
+	                          URLs, hosts file and adblock style (||example.com^) entries
+	                          are accepted for domains, also on the command line.
+	        [--schedule <s>]  Only enforce the restriction during the time windows of the
+	                          schedule, i.e. "Mon-Fri 09:00-17:30; Sat,Sun 10:00-12:00".
 	:del    <type> <args>   Removes an existing restriction.
+	        [--schedule]      Only removes the schedule, the restriction stays in effect.
+	:status                 Compares the configuration, the last commit and the actual
+	                        state of the OS. Reports the changes not commited yet, the
+	                        commited restrictions removed or modified outside of dnd and
+	                        the restrictions of dnd not known to the last commit. Exits
+	                        with 0 if all agree, 2 on drift and 1 on failure.
+	        [--output <f>]    Prints the report as json, yaml or table.
 	:print                  Prints the configured restrictions.
+	        [--output <f>]    Prints them as json, yaml or table instead of the raw config.
+	:profile <cmd> <args>   Manages named sets of restrictions, see ':profile help'.
+	:allow  <type> <item>   Temporarily lifts a commited restriction, it is restored by the
+	        --for <d>         daemon or the next commit once the duration passes. The number
+	                          of allowances per day is limited, see [--budget <n>].
+	:subscribe <name> <src> Subscribes to a blocklist at the URL or path, its domains are
+	                        blocked as a single group. The list is cached and refreshed on
+	                        commit once a day, falling back to the cached copy on failure.
+	:subscribe [--refresh]  Lists the subscriptions, or refreshes them.
+	:unsubscribe <name>     Removes the subscription.
+	:sinkhole               Sets the addresses the blocked domains resolve to, defaults to
+	        [--ipv4 <addr>]   127.0.0.1 and ::1. Without options prints the addresses in use.
+	        [--ipv6 <addr>]   Only loopback and unspecified addresses are accepted.
+	:lock   [--until <t>]   Refuses to remove commited restrictions until the deadline,
+	        [--for <d>]       given as a time of day (18:00), a date (2006-01-02 15:04)
+	                          or a duration (2h). Without options prints the active lock.
+	:history                Lists the versions of the config with the time, the user and
+	                        the command that wrote them.
+	:diff   <v1> <v2>       Prints the changes of the restrictions between the versions.
+	:checkout <version>     Restores the restrictions and settings of the version and
+	        [--yes]           commits them. The lock and the allowances are kept.
+	:undo   [--yes]         Checks out the version preceding the current one, undo
+	                        again to redo.
+	:restore [--list]       Lists the backups of /etc/hosts and of the application
+	                        restrictions taken before every commit, the newest first.
+	:restore <id>           Rolls back to the backup, the current state is backed up
+	                        first. The daemon restores the commited restrictions missing
+	                        from the backup. Requires sudo.
 	:types                  Prints all available types.
-
-The program will store its configuration under $HOME/.dnd_config.
+	        [--output <f>]    Prints them as json, yaml or table.
+	:daemon                 Runs in the foreground and kills any process matching
+	                        the commited application restrictions as soon as it starts.
+	                        Applies and reverts scheduled restrictions at the boundaries
+	                        of their time windows. Restores the commited restrictions
+	                        removed or modified outside of dnd, i.e. by editing /etc/hosts,
+	                        and records the tampering in the audit trail.
+	                        Must run as root through sudo or pkexec, i.e. sudo dnd daemon.
+
+add, del and print accept [--profile <name>] to operate on a profile other
+than the one in use.
+
+The program will store its configuration under $HOME/.dnd_config. Commands
+changing it wait for any other invocation changing it to finish first.
+
+Every command accepts [--root <dir>], or the DND_ROOT environment variable,
+to rebase every path read or written onto the directory, i.e. /etc/hosts
+becomes <dir>/etc/hosts. Under a root no privileges are needed and the
+service managers are not invoked, allowing to try out a commit end to end.
 `
 
 func help(w io.Writer) { fmt.Fprintln(w, Usage) }
 
 func add(w io.Writer, r io.Reader, args ...string) {
+	args, opts, err := parseFlags(args, "schedule", "profile", "f")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	var schedule restrictions.Schedule
+	if opts.Has("schedule") {
+		if schedule, err = restrictions.ParseSchedule(opts["schedule"]); err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+	}
+
 	if len(args) < 1 {
 		fmt.Fprintf(w, "no <type> specified\n")
 		return
 	}
 
-	typ := strings.TrimSpace(args[0])
-	typ = strings.ToLower(typ)
-	typ = cases.Title(language.AmericanEnglish).String(typ)
-
-	matched := restrictions.TypeFromString[typ]
-	if matched == restrictions.Invalid {
-		fmt.Fprintf(w, "invalid type %v\n", args[0])
+	matched, err := restrictions.ParseType(args[0])
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
 		return
 	}
+	kind, _ := restrictions.KindOf(matched)
 
-	if len(args) < 2 {
+	var entries []string
+	switch {
+	case opts.Has("f"):
+		if entries, err = readEntries(r, opts["f"]); err != nil {
+			fmt.Fprintf(w, "failed to read entries from %s: %v\n", opts["f"], err)
+			return
+		}
+	case len(args) < 2:
 		fmt.Fprintf(w, "no <args> specified\n")
 		return
+	default:
+		entries = restrictions.List(args[1]).Items()
 	}
 
+	var items []string
+	for _, entry := range entries {
+		parsed, err := kind.Parse(entry)
+		if err != nil {
+			fmt.Fprintf(w, "%v, skipping...\n", err)
+		}
+		items = append(items, parsed...)
+	}
+
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
 	c, err := restrictions.ReadConfig()
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
 			return
 		}
-		c = &restrictions.Config{Restrictions: make(map[restrictions.Type]restrictions.List)}
+		c = &restrictions.Config{}
+	}
+
+	p, err := c.Lookup(opts["profile"])
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
 	}
 
 	processed := 0
+	existing := make(map[string]struct{})
+	for _, item := range p.Restrictions[matched].Items() {
+		existing[item] = struct{}{}
+	}
 
-	for _, item := range restrictions.List(args[1]).Items() {
+	for _, item := range items {
 		item := strings.TrimSpace(item)
 		if item == "" {
 			continue
 		}
 
-		if matched == restrictions.Application {
-			apps := FindApplicationBasedOnPattern(w, item)
-			apps = append(apps, Match{program: item, dir: "general purporse pattern match"})
-			fmt.Fprintf(w, "found %v matches based on provided pattern %q\n", len(apps), item)
+		if kind.Candidates != nil {
+			candidates := kind.Candidates(item)
+			candidates = append(candidates, restrictions.Candidate{Item: item, Description: fmt.Sprintf("%s | general purporse pattern match", item)})
+			fmt.Fprintf(w, "found %v matches based on provided pattern %q\n", len(candidates), item)
 			fmt.Fprintf(w, "which one do you want to proceed with:\n")
-			for i, v := range apps {
-				fmt.Fprintf(w, "[%v]\t%s | %s\n", i+1, v.program, v.dir)
+			for i, v := range candidates {
+				fmt.Fprintf(w, "[%v]\t%s\n", i+1, v.Description)
 			}
-			fmt.Fprintf(w, "choose number between [%v, %v]: ", 1, len(apps))
+			fmt.Fprintf(w, "choose number between [%v, %v]: ", 1, len(candidates))
 			b := make([]byte, 3) // dont expect more than 999 matches...
 			read, _ := bufio.NewReader(r).Read(b)
-			if b[read-1] == '\n' {
+			if read > 0 && b[read-1] == '\n' {
 				read--
 			}
 
@@ -93,18 +205,24 @@ func add(w io.Writer, r io.Reader, args ...string) {
 				fmt.Fprintf(w, "failed to parse input: %v, skipping...\n", err)
 				continue
 			}
-			if !((selected-1) >= 0 && ((selected - 1) < len(apps))) {
+			if !((selected-1) >= 0 && ((selected - 1) < len(candidates))) {
 				fmt.Fprintf(w, "invalid input: %v, skipping...\n", selected)
 				continue
 			}
-			item = apps[selected-1].program
-			if selected != len(apps) {
-				item = filepath.Join(apps[selected-1].dir, apps[selected-1].program)
-			}
-			fmt.Fprintf(w, "option %v will be used to kill any processes that contains the given pattern %q\n", selected, item)
+			item = candidates[selected-1].Item
+			fmt.Fprintf(w, "option %v will be used for the pattern %q\n", selected, item)
 		}
 
-		c.Restrictions[matched] = c.Restrictions[matched].Append(item)
+		if _, ok := existing[item]; ok {
+			fmt.Fprintf(w, "%q already present, skipping...\n", item)
+			continue
+		}
+		existing[item] = struct{}{}
+
+		p.Restrictions[matched] = p.Restrictions[matched].Append(item)
+		if schedule != "" {
+			p.SetSchedule(matched, item, schedule)
+		}
 		processed += 1
 	}
 
@@ -120,26 +238,63 @@ func add(w io.Writer, r io.Reader, args ...string) {
 	fmt.Fprintf(w, "processed %v items\n", processed)
 }
 
+// readEntries reads one entry per line from the file
+// at path, or from r if the path is "-".
+func readEntries(r io.Reader, path string) ([]string, error) {
+	if path == "-" {
+		return readLines(r)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readLines(f)
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, s.Err()
+}
+
 func del(w io.Writer, args ...string) {
+	args, opts, err := parseFlags(args, "profile")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
 	if len(args) < 1 {
 		fmt.Fprintf(w, "no <type> specified\n")
 		return
 	}
 
-	typ := strings.TrimSpace(args[0])
-	typ = strings.ToLower(typ)
-	typ = cases.Title(language.AmericanEnglish).String(typ)
-
-	if got := restrictions.TypeFromString[typ]; got == restrictions.Invalid || got == restrictions.TypeEnd {
-		fmt.Fprintf(w, "invalid type %v", args[0])
+	matched, err := restrictions.ParseType(args[0])
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
 		return
 	}
+	kind, _ := restrictions.KindOf(matched)
 
 	if len(args) < 2 {
 		fmt.Fprintf(w, "no <args> specified\n")
 		return
 	}
 
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
 	c, err := restrictions.ReadConfig()
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -149,13 +304,43 @@ func del(w io.Writer, args ...string) {
 		return
 	}
 
+	p, err := c.Lookup(opts["profile"])
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	if err := restrictions.CheckLock(c, time.Now()); err != nil && p == &c.Profile {
+		fmt.Fprintf(w, "%v, refusing to delete\n", err)
+		return
+	}
+
 	processed := 0
-	current, ok := c.Restrictions[restrictions.TypeFromString[typ]]
+	current, ok := p.Restrictions[matched]
 	if !ok {
 		return
 	}
 
-	for _, item := range restrictions.List(args[1]).Items() {
+	var items []string
+	for _, entry := range restrictions.List(args[1]).Items() {
+		// items added before the validation was introduced
+		// may not be valid anymore, those are removed as they are.
+		parsed, err := kind.Parse(entry)
+		if err != nil || len(parsed) == 0 {
+			parsed = []string{strings.TrimSpace(entry)}
+		}
+		items = append(items, parsed...)
+	}
+
+	for _, item := range items {
+		// only drop the schedule, the restriction stays in effect at all times.
+		if opts.Has("schedule") {
+			if _, ok := p.Schedules[matched][item]; ok {
+				p.SetSchedule(matched, item, "")
+				processed++
+			}
+			continue
+		}
 		for {
 			n, deleted := current.Remove(item)
 			if !deleted {
@@ -164,12 +349,13 @@ func del(w io.Writer, args ...string) {
 			processed++
 			current = n
 		}
+		p.SetSchedule(matched, item, "")
 	}
 
 	if current.Empty() {
-		delete(c.Restrictions, restrictions.TypeFromString[typ])
+		delete(p.Restrictions, matched)
 	} else {
-		c.Restrictions[restrictions.TypeFromString[typ]] = current
+		p.Restrictions[matched] = current
 	}
 
 	if err := restrictions.WriteConfig(c); err != nil {
@@ -180,85 +366,488 @@ func del(w io.Writer, args ...string) {
 	fmt.Fprintf(w, "processed %v items\n", processed)
 }
 
-func print(w io.Writer) {
+func print(w io.Writer, args ...string) {
+	_, opts, err := parseFlags(args, "profile", "output")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	format, err := parseOutput(opts)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
 	c, err := restrictions.ReadConfig()
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
 			return
 		}
-		fmt.Fprintln(w, "{}")
+		if format == "" {
+			fmt.Fprintln(w, "{}")
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	if format != "" {
+		out, err := newPrintOutput(c, opts["profile"], time.Now())
+		if err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		if err := encode(w, format, out); err != nil {
+			fmt.Fprintf(w, "failed to encode output: %v\n", err)
+		}
 		return
 	}
 
-	b, err := json.Marshal(c)
+	var v any = c
+	if opts.Has("profile") {
+		if v, err = c.Lookup(opts["profile"]); err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+	}
+
+	b, err := json.Marshal(v)
 	if err != nil {
 		fmt.Fprintln(w, "{}")
 		return
 	}
 
 	fmt.Fprintf(w, "%s\n", string(b))
+
+	p, err := c.Lookup(opts["profile"])
+	if err != nil {
+		return
+	}
+	for _, item := range p.Restrictions[restrictions.Domain].Items() {
+		if expanded := restrictions.ExpandDomain(item); len(expanded) > 1 {
+			fmt.Fprintf(w, "%s expands to %v\n", item, expanded)
+		}
+	}
+	if !opts.Has("profile") || opts["profile"] == c.CurrentProfile() {
+		for _, f := range failedItems(c) {
+			fmt.Fprintf(w, "%s %s failed at the last commit: %s\n", f.Type, f.Item, f.Error)
+		}
+	}
 }
 
-func types(w io.Writer) {
+func types(w io.Writer, args ...string) {
+	_, opts, err := parseFlags(args, "output")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	format, err := parseOutput(opts)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	if format != "" {
+		if err := encode(w, format, newTypesOutput()); err != nil {
+			fmt.Fprintf(w, "failed to encode output: %v\n", err)
+		}
+		return
+	}
+
 	builder := new(strings.Builder)
-	builder.WriteString(fmt.Sprintf("-%s: A single domain name or a list of domains separated with ',' [www.google.com,www.youtube.com]\n", restrictions.Type(1).String()))
-	builder.WriteString(fmt.Sprintf("-%s: A single application name or a list of applications names separated with ',' [spotify, chrome]\n", restrictions.Type(2).String()))
+	for _, k := range restrictions.Kinds() {
+		builder.WriteString(fmt.Sprintf("-%s: %s\n", k.Name, k.Help))
+	}
 	fmt.Fprintf(w, "%s", builder.String())
 }
 
-func commit(out io.Writer, in io.Reader) {
+// exit statuses of the commit command.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	// exitPending is returned by a dry run if changes are pending
+	// and by status if the state drifted.
+	exitPending = 2
+)
+
+// commitOptions control which restrictions are commited and how.
+type commitOptions struct {
+	// Types to commit, all registered kinds if empty.
+	Types []restrictions.Type
+	// DryRun only prints the differences without commiting them.
+	DryRun bool
+	// Yes commits without asking for confirmation.
+	Yes bool
+	// Output is the format of a dry run, see parseOutput.
+	Output string
+	// Explain commits in a sandbox, printing the external
+	// commands a commit would run, see restrictions.Sandbox.
+	Explain bool
+}
+
+func commit(out io.Writer, in io.Reader, args ...string) int {
+	args, opts, err := parseFlags(args, "output")
+	if err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+		return exitFailure
+	}
+
+	var o commitOptions
+	if o.Output, err = parseOutput(opts); err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+		return exitFailure
+	}
+	for name := range opts {
+		switch name {
+		case "dry-run":
+			o.DryRun = true
+		case "yes", "y":
+			o.Yes = true
+		case "explain":
+			o.Explain = true
+		case "output":
+		default:
+			fmt.Fprintf(out, "unknown option %s\n", name)
+			return exitFailure
+		}
+	}
+	for _, arg := range args {
+		t, err := restrictions.ParseType(arg)
+		if err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+			return exitFailure
+		}
+		o.Types = append(o.Types, t)
+	}
+	if o.Output != "" && !o.DryRun {
+		fmt.Fprintf(out, "--output is only supported with --dry-run\n")
+		return exitFailure
+	}
+
 	c, err := restrictions.ReadConfig()
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
 			fmt.Fprintf(out, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
-			return
+			return exitFailure
 		}
 		c = &restrictions.Config{}
 	}
 
-	for t := restrictions.Type(1); t < restrictions.Type(restrictions.TypeEnd); t++ {
-		diff, err := t.Diff(c.Restrictions[t])
+	return commitConfig(out, in, c, o)
+}
+
+// commitConfig commits the restrictions of the profile in use
+// and records them as the last commited state in the config.
+// Returns the exit status of the commit.
+func commitConfig(out io.Writer, in io.Reader, c *restrictions.Config, o commitOptions) int {
+	now := time.Now()
+
+	var explained *restrictions.Recorder
+	if o.Explain && !o.DryRun {
+		rec, restore, err := restrictions.Sandbox()
+		if err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+			return exitFailure
+		}
+		defer restore()
+		explained = rec
+		o.Yes = true
+	}
+
+	if !o.DryRun && explained == nil {
+		refreshSubscriptions(out, c, now)
+	}
+
+	types := o.Types
+	if len(types) == 0 {
+		for _, k := range restrictions.Kinds() {
+			types = append(types, k.Type)
+		}
+	}
+
+	status := exitOK
+	pending := false
+	r := bufio.NewReader(in)
+	report := commitOutput{SchemaVersion: schemaVersion, Diffs: []diffOutput{}}
+
+	// the changes of all types are staged and commited together,
+	// landed are the types whose desired state is in effect afterwards.
+	var staged []restrictions.Diff
+	landed := make(map[restrictions.Type]bool)
+	// the changes left in effect by a commit that failed to roll back.
+	inEffect := make(map[restrictions.Type]restrictions.Diff)
+	outcomes := make(map[restrictions.Type]map[string]restrictions.Result)
+
+	for _, t := range types {
+		// with a machine readable output the messages
+		// are reported as part of the difference.
+		w, messages := out, new(strings.Builder)
+		if o.Output != "" {
+			w = messages
+		}
+
+		diff, err := c.Diff(t, now)
 		if err != nil {
 			if !errors.Is(err, restrictions.ErrPartialSync) {
-				fmt.Fprintf(out, "failed to determine difference between actual and desired state: %v", err)
+				fmt.Fprintf(w, "failed to determine difference between actual and desired state: %v\n", err)
+				status = exitFailure
+				outcomes[t] = results(c, restrictions.Diff{Type: t}, now, restrictions.Result{Outcome: restrictions.OutcomeFailed, Error: err.Error()}, nil)
+				if o.Output != "" {
+					report.Diffs = append(report.Diffs, newDiffOutput(restrictions.Diff{Type: t}, messages.String()))
+				}
 				continue
 			}
-			fmt.Fprintf(out, "partially synced actuall state from the OS, continuing\nfailed operations: %v\n", err)
+			fmt.Fprintf(w, "partially synced actuall state from the OS, continuing\nfailed operations: %v\n", err)
+		}
+		if o.Output == "" {
+			diff.Print(out)
 		}
-		diff.Print(out)
 
-		if len(diff.Delete) == 0 && len(diff.Missing) == 0 {
+		changed := len(diff.Delete) > 0 || len(diff.Missing) > 0
+		pending = pending || changed
+
+		if err := restrictions.CheckLock(c, now); changed && err != nil && len(diff.Removed()) > 0 {
+			fmt.Fprintf(w, "%v, refusing to delete %v %s restrictions, aborting...\n", err, len(diff.Removed()), t)
+			status = exitFailure
+			outcomes[t] = results(c, diff, now, restrictions.Result{Outcome: restrictions.OutcomeSkipped, Error: err.Error()}, nil)
+			if o.Output != "" {
+				report.Diffs = append(report.Diffs, newDiffOutput(diff, messages.String()))
+			}
 			continue
 		}
 
-		fmt.Fprintf(out, "commit ? (yes/no): ")
-		b := make([]byte, 3)
-		r := bufio.NewReader(in)
-		r.Reset(in)
-		r.Read(b)
-		if string(b) != "yes" {
-			fmt.Fprintf(out, "aborting...\n")
+		if o.Output != "" {
+			report.Diffs = append(report.Diffs, newDiffOutput(diff, messages.String()))
+		}
+		if !changed {
+			landed[t] = true
+			outcomes[t] = results(c, diff, now, restrictions.Result{Outcome: restrictions.OutcomeApplied}, nil)
 			continue
 		}
-		if err := diff.Commit(); err != nil {
-			if !errors.Is(err, restrictions.ErrPartialCommit) {
-				fmt.Fprintf(out, "failed to commit: %v, aborting...\n", err)
+		if o.DryRun {
+			continue
+		}
+
+		if !o.Yes {
+			fmt.Fprintf(out, "commit ? (yes/no): ")
+			answer, _ := r.ReadString('\n')
+			if strings.TrimSpace(answer) != "yes" {
+				fmt.Fprintf(out, "aborting...\n")
+				outcomes[t] = results(c, diff, now, restrictions.Result{Outcome: restrictions.OutcomeSkipped, Error: "commit declined"}, nil)
 				continue
 			}
-			fmt.Fprintf(out, "partially commited state to the OS, continuing\nfailed operations: %v\n", err)
+		}
+		staged = append(staged, diff)
+	}
+
+	// once commited, the lock is also kept where the user can't modify it.
+	var lock *restrictions.Lock
+	if _, locked, err := restrictions.LockedUntil(c, now); err == nil && locked && c.Lock != nil && explained == nil {
+		lock = c.Lock
+	}
+
+	if !o.DryRun && len(staged) > 0 {
+		// the process is marked from the commit until the commited
+		// state is written, so that the daemon doesn't repair the
+		// changes meanwhile, see restrictions.Applying.
+		if explained == nil {
+			done, err := restrictions.MarkApplying()
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+				return exitFailure
+			}
+			defer done()
+		}
+		// the state before the first change is backed up, see restore,
+		// and the lock is stored along with the changes.
+		b, err := restrictions.Commit(staged, restrictions.CommitOptions{Backup: explained == nil, Lock: lock}, now)
+		if b != nil {
+			fmt.Fprintf(out, "backed up the current state as %s\n", b.ID)
+		}
+		var partial *restrictions.PartialCommitError
+		switch {
+		case err == nil:
+			for _, d := range staged {
+				landed[d.Type] = true
+			}
+		case errors.Is(err, restrictions.ErrRolledBack):
+			fmt.Fprintf(out, "%v, none of the changes were commited\n", err)
+			status = exitFailure
+		case errors.As(err, &partial):
+			for _, d := range partial.InEffect {
+				inEffect[d.Type] = d
+			}
+			fallthrough
+		default:
+			fmt.Fprintf(out, "%v\nthe actual state is left partially commited, see `dnd status`\n", err)
+			status = exitFailure
+		}
+		// the items left in effect by a partial commit are applied, see Results.
+		result := restrictions.Result{Outcome: restrictions.OutcomeApplied}
+		switch {
+		case err == nil:
+		case errors.Is(err, restrictions.ErrRolledBack), errors.Is(err, restrictions.ErrPartialCommit):
+			result = restrictions.Result{Outcome: restrictions.OutcomeSkipped, Error: "rolled back"}
+		default:
+			result = restrictions.Result{Outcome: restrictions.OutcomeFailed, Error: err.Error()}
+		}
+		for _, d := range staged {
+			outcomes[d.Type] = results(c, d, now, result, err)
+		}
+
+		if explained != nil {
+			commands := explained.Commands()
+			fmt.Fprintf(out, "commands run by the commit [%v]\n", len(commands))
+			for _, cmd := range commands {
+				fmt.Fprintf(out, "\t%s\n", cmd)
+			}
+			fmt.Fprintln(out)
+		}
+	}
+
+	if o.DryRun || explained != nil {
+		if o.Output != "" {
+			report.Pending = pending
+			if err := encode(out, o.Output, report); err != nil {
+				fmt.Fprintf(out, "failed to encode output: %v\n", err)
+				return exitFailure
+			}
+		}
+		if status == exitOK && pending {
+			status = exitPending
+		}
+		return status
+	}
+
+	if lock != nil && len(staged) == 0 {
+		if err := restrictions.StoreLock(*lock); err != nil {
+			fmt.Fprintf(out, "failed to store lock: %v\n", err)
+			status = exitFailure
 		}
 	}
 
 	// shallow clone, doesn't matter since we're dealing with strings.
-	c.LastCommited = &restrictions.Config{
+	commited := &restrictions.Config{
 		LastCommited: nil,
 		Version:      c.Version,
-		Restrictions: maps.Clone(c.Restrictions),
+		Current:      c.Current,
+		Profile: restrictions.Profile{
+			Restrictions: maps.Clone(c.Restrictions),
+			Schedules:    maps.Clone(c.Schedules),
+		},
+		Allowances:    c.ActiveAllowances(now),
+		Sinkhole:      c.Sinkhole,
+		Subscriptions: c.Subscriptions,
+		Results:       outcomes,
 	}
 
-	if err := restrictions.WriteConfig(c); err != nil {
+	// the types that did not land keep their last commited state.
+	previous := c.LastCommited
+	if previous == nil {
+		previous = &restrictions.Config{}
+	}
+	for _, k := range restrictions.Kinds() {
+		if !slices.Contains(types, k.Type) {
+			commited.Results = keepType(commited.Results, previous.Results, k.Type)
+		}
+		if landed[k.Type] {
+			continue
+		}
+		commited.Restrictions = keepType(commited.Restrictions, previous.Restrictions, k.Type)
+		commited.Schedules = keepType(commited.Schedules, previous.Schedules, k.Type)
+		if d, ok := inEffect[k.Type]; ok {
+			keepInEffect(commited, previous, c, d)
+		}
+		if k.Type == restrictions.Domain {
+			commited.Sinkhole = previous.Sinkhole
+			commited.Subscriptions = previous.Subscriptions
+		}
+	}
+	c.LastCommited = commited
+
+	// the config is only locked to be written. If it was changed meanwhile,
+	// e.g. while prompting, the commit is still recorded on top of the
+	// changes, as the OS was changed already.
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(out, "%v\n", err)
+		return exitFailure
+	}
+	defer unlock()
+
+	err = restrictions.WriteConfig(c)
+	if errors.Is(err, restrictions.ErrConflict) {
+		var current *restrictions.Config
+		if current, err = restrictions.ReadConfig(); err == nil {
+			current.LastCommited = c.LastCommited
+			if err = restrictions.WriteConfig(current); err == nil {
+				fmt.Fprintf(out, "the config was changed during the commit, only the commit was recorded\n")
+			}
+		}
+	}
+	if err != nil {
 		fmt.Fprintf(out, "failed to update config: %v\n", err)
-		return
+		return exitFailure
+	}
+	return status
+}
+
+// results records the outcome of every configured item of the type
+// of the diff, the items not in effect at the time are skipped.
+func results(c *restrictions.Config, d restrictions.Diff, now time.Time, result restrictions.Result, err error) map[string]restrictions.Result {
+	out := d.Results(c, c.Active(now)[d.Type], result, err)
+	for _, item := range c.Restrictions[d.Type].Items() {
+		if _, ok := out[item]; !ok {
+			out[item] = restrictions.Result{Outcome: restrictions.OutcomeSkipped, Error: "not in effect at the time of the commit"}
+		}
+	}
+	return out
+}
+
+// keepInEffect records the items of the type of the diff that are
+// in effect after a partial commit, with their schedules.
+func keepInEffect(commited, previous, c *restrictions.Config, d restrictions.Diff) {
+	l, schedules := d.InEffect(c, previous)
+	if commited.Restrictions == nil {
+		commited.Restrictions = make(map[restrictions.Type]restrictions.List)
+	}
+	commited.Restrictions[d.Type] = l
+
+	delete(commited.Schedules, d.Type)
+	if len(schedules) > 0 {
+		if commited.Schedules == nil {
+			commited.Schedules = make(map[restrictions.Type]map[string]restrictions.Schedule)
+		}
+		commited.Schedules[d.Type] = schedules
+	}
+}
+
+// keepType sets the entry of the type in dst to the one in src.
+func keepType[V any](dst, src map[restrictions.Type]V, t restrictions.Type) map[restrictions.Type]V {
+	v, ok := src[t]
+	if !ok {
+		delete(dst, t)
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[restrictions.Type]V)
+	}
+	dst[t] = v
+	return dst
+}
+
+// enforce commits the difference between the restrictions of the config
+// in effect at the given time and the actual state of the OS without
+// asking for confirmation.
+func enforce(c *restrictions.Config, t restrictions.Type, now time.Time) (restrictions.Diff, error) {
+	diff, err := c.Diff(t, now)
+	if err != nil && !errors.Is(err, restrictions.ErrPartialSync) {
+		return diff, err
+	}
+	if len(diff.Delete) == 0 && len(diff.Missing) == 0 {
+		return diff, nil
+	}
+	if err := restrictions.CheckLock(nil, now); err != nil && len(diff.Removed()) > 0 {
+		return diff, fmt.Errorf("%w, refusing to delete %v restrictions", err, len(diff.Removed()))
 	}
+	return diff, diff.Commit()
 }
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+// scanInterval is how often the running processes are checked
+// against the commited application restrictions.
+const scanInterval = 250 * time.Millisecond
+
+// repairDelay is how long a change to the watched files is left as is
+// before it is repaired. A commit changes them before writing the new
+// version of the config, which takes the changes as they are.
+const repairDelay = 5 * time.Second
+
+// daemon enforces the commited application restrictions by killing
+// any matching process shortly after it was started and applies the
+// scheduled restrictions once their time window starts or ends. Commited
+// restrictions removed or modified outside of dnd are restored. Runs
+// until interrupted.
+//
+// The daemon runs as root elevated by the user whose restrictions it
+// enforces, i.e. `sudo dnd daemon`, so that it never prompts for a
+// password to restore them and reads the config from the home of that
+// user. Any other mode is refused, except under a root.
+func daemon(w io.Writer) {
+	if restrictions.Root() == "" && !restrictions.Elevated() {
+		fmt.Fprintf(w, "the daemon must run as root elevated by the user whose restrictions it enforces, i.e. sudo dnd daemon\n")
+		return
+	}
+
+	logger := log.New(w, "", log.LstdFlags)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		commited *restrictions.Config
+		applied  map[restrictions.Type]restrictions.List
+		matchers []restrictions.ProcessMatcher
+		modTime  time.Time
+		version  int64 = -1
+		self           = os.Getpid()
+		watched        = make(map[restrictions.Type]string)
+		// changed is when the watched files of a type were found changed.
+		changed = make(map[restrictions.Type]time.Time)
+	)
+
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	for {
+		// reload whenever the config was changed, i.e. by `dnd commit`.
+		if info, err := os.Stat(restrictions.ConfigPath()); err == nil && !info.ModTime().Equal(modTime) {
+			modTime = info.ModTime()
+			c, err := restrictions.ReadConfig()
+			if err != nil {
+				logger.Printf("failed to read config %s: %v", restrictions.ConfigPath(), err)
+			} else if c.Version != version {
+				// the changes made by the commit of the new version before
+				// it was written are not tampering, see repairDelay.
+				if version >= 0 {
+					for _, k := range restrictions.Kinds() {
+						if k.Watch != nil {
+							watched[k.Type] = fingerprint(k.Watch())
+						}
+					}
+					clear(changed)
+				}
+				version = c.Version
+				commited = c.LastCommited
+				logger.Printf("loaded config version %v", version)
+			}
+		}
+
+		// the scheduled restrictions are applied and reverted
+		// whenever a boundary of their time window is crossed.
+		if commited != nil {
+			now := time.Now()
+			active := commited.Active(now)
+			if !maps.Equal(active, applied) {
+				for _, k := range restrictions.Kinds() {
+					t := k.Type
+					if applied != nil && active[t] == applied[t] {
+						continue
+					}
+					diff, err := enforce(commited, t, now)
+					if err != nil {
+						logger.Printf("failed to enforce %s restrictions: %v", t, err)
+						continue
+					}
+					if len(diff.Missing) > 0 || len(diff.Delete) > 0 {
+						logger.Printf("enforced %s restrictions, added %v, deleted %v", t, len(diff.Missing), len(diff.Delete))
+					}
+				}
+				applied = active
+				matchers = activeMatchers(logger, active)
+				logger.Printf("enforcing %v application restrictions", len(matchers))
+			}
+		}
+
+		// the files where the restrictions are enforced are checked
+		// for tampering whenever they change, unless dnd changes them.
+		if commited != nil && !restrictions.Applying() {
+			for _, k := range restrictions.Kinds() {
+				if k.Watch == nil {
+					continue
+				}
+				if fingerprint(k.Watch()) == watched[k.Type] {
+					delete(changed, k.Type)
+					continue
+				}
+				if since, ok := changed[k.Type]; !ok || time.Since(since) < repairDelay {
+					if !ok {
+						changed[k.Type] = time.Now()
+					}
+					continue
+				}
+				delete(changed, k.Type)
+				repair(logger, commited, k.Type, time.Now())
+				// ignore the changes made by the repair.
+				watched[k.Type] = fingerprint(k.Watch())
+			}
+		}
+
+		// under a root the restrictions are not meant for
+		// the processes running on the system.
+		if len(matchers) > 0 && restrictions.Root() == "" {
+			procs, err := restrictions.Processes()
+			if err != nil {
+				logger.Printf("failed to list processes: %v", err)
+			}
+			for _, p := range procs {
+				if p.PID == self {
+					continue
+				}
+				for _, m := range matchers {
+					if !m.Match(p) {
+						continue
+					}
+					if err := p.Kill(); err != nil {
+						logger.Printf("failed to kill pattern=%q pid=%v cmdline=%q: %v", m.Pattern, p.PID, p.Cmdline, err)
+						break
+					}
+					logger.Printf("killed pattern=%q pid=%v cmdline=%q", m.Pattern, p.PID, p.Cmdline)
+					break
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Printf("shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// repair restores the commited restrictions that were
+// removed or modified outside of dnd.
+func repair(logger *log.Logger, c *restrictions.Config, t restrictions.Type, now time.Time) {
+	diff, err := c.Diff(t, now)
+	if err != nil && !errors.Is(err, restrictions.ErrPartialSync) {
+		logger.Printf("failed to check %s restrictions for tampering: %v", t, err)
+		return
+	}
+
+	repair := diff.Repair()
+	if len(repair.Missing) == 0 {
+		return
+	}
+	for _, r := range repair.Missing {
+		change := "removed"
+		if slices.ContainsFunc(repair.Delete, func(d restrictions.Restriction) bool { return d.Key() == r.Key() }) {
+			change = "modified"
+		}
+		logger.Printf("tamper detected, %s restriction %s outside of dnd: %s", t, change, summarize(r.Items()))
+		if err := restrictions.Audit(restrictions.AuditEntry{
+			Time:   now,
+			Action: "tamper",
+			Type:   t.String(),
+			Item:   summarize(r.Items()),
+		}); err != nil {
+			logger.Printf("failed to record the tampering in the audit trail %s: %v", restrictions.AuditPath(), err)
+		}
+	}
+
+	if err := repair.Commit(); err != nil {
+		logger.Printf("failed to repair %s restrictions: %v", t, err)
+		return
+	}
+	logger.Printf("repaired %v %s restrictions", len(repair.Missing), t)
+}
+
+// fingerprint summarizes the size and modification time of the files,
+// and of the entries of the directories, to detect any changes to them.
+func fingerprint(paths []string) string {
+	b := new(strings.Builder)
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			fmt.Fprintf(b, "%s:%v;", p, err)
+			continue
+		}
+		fmt.Fprintf(b, "%s:%v:%v;", p, info.Size(), info.ModTime().UnixNano())
+		if !info.IsDir() {
+			continue
+		}
+		entries, _ := os.ReadDir(p)
+		for _, e := range entries {
+			if info, err := e.Info(); err == nil {
+				fmt.Fprintf(b, "%s:%v:%v;", e.Name(), info.Size(), info.ModTime().UnixNano())
+			}
+		}
+	}
+	return b.String()
+}
+
+func activeMatchers(logger *log.Logger, active map[restrictions.Type]restrictions.List) []restrictions.ProcessMatcher {
+	var matchers []restrictions.ProcessMatcher
+	for _, item := range active[restrictions.Application].Items() {
+		m, err := restrictions.NewProcessMatcher(item)
+		if err != nil {
+			logger.Printf("skipping: %v", err)
+			continue
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers
+}
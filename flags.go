@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// flags are the options passed on the command line
+// in the form --name value or --name=value.
+type flags map[string]string
+
+func (f flags) Has(name string) bool { _, ok := f[name]; return ok }
+
+// parseFlags separates the options from the positional arguments.
+// Options may appear anywhere on the command line. Only the options
+// listed in valued consume a value, every other option is treated
+// as a switch. Everything after "--" is positional.
+func parseFlags(args []string, valued ...string) ([]string, flags, error) {
+	var positional []string
+	f := make(flags)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !slices.Contains(valued, name) {
+			if hasValue {
+				return nil, nil, fmt.Errorf("option %s does not accept a value", name)
+			}
+			f[name] = "true"
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("option %s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		f[name] = value
+	}
+
+	return positional, f, nil
+}
+
+// extractOption removes the valued option from anywhere on the command
+// line, before the "--" separator, returning its value. Used for the
+// options accepted by every command.
+func extractOption(args []string, name string) ([]string, string, error) {
+	var rest []string
+	value := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			rest = append(rest, arg)
+			continue
+		}
+		got, v, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if got != name {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, "", fmt.Errorf("option %s requires a value", name)
+			}
+			i++
+			v = args[i]
+		}
+		value = v
+	}
+	return rest, value, nil
+}
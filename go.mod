@@ -2,4 +2,9 @@ module github.com/Despire/dnd
 
 go 1.23.0
 
-require golang.org/x/text v0.15.0
+require (
+	golang.org/x/net v0.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/text v0.15.0 // indirect
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+// history lists the recorded versions of the config, the newest last.
+func history(w io.Writer) {
+	entries, err := restrictions.ReadHistory()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(w, "no history\n")
+		return
+	}
+	for _, e := range entries {
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\n", e.Version, e.Time.Format(time.DateTime), e.User, e.Command)
+	}
+}
+
+// diffVersions prints the changes of the restrictions between two versions of the config.
+func diffVersions(w io.Writer, args ...string) {
+	if len(args) < 2 {
+		fmt.Fprintf(w, "no <v1> <v2> specified\n")
+		return
+	}
+
+	entries, err := restrictions.ReadHistory()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	var configs [2]*restrictions.Config
+	for i, arg := range args[:2] {
+		v, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			fmt.Fprintf(w, "invalid version %q\n", arg)
+			return
+		}
+		e, err := restrictions.LookupVersion(entries, v)
+		if err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		configs[i] = &e.Config
+	}
+
+	drift := changes(configs[0], configs[1])
+	if len(drift) == 0 {
+		fmt.Fprintf(w, "no changes\n")
+		return
+	}
+	symbols := map[string]string{
+		statePendingAdd:      "+",
+		statePendingDelete:   "-",
+		statePendingSchedule: "@",
+		statePendingSetting:  "~",
+	}
+	for _, d := range drift {
+		fmt.Fprintf(w, "%s %-11s %s\n", symbols[d.State], d.Type, summarize(d.Items))
+	}
+}
+
+// undo checks out the version preceding the current one.
+func undo(w io.Writer, r io.Reader, args ...string) {
+	_, opts, err := parseFlags(args)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	// commitConfig locks the config to write it.
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+		return
+	}
+	checkoutVersion(w, r, c, c.Version-1, opts.Has("yes"))
+}
+
+// checkout restores a version of the config and commits it.
+func checkout(w io.Writer, r io.Reader, args ...string) {
+	args, opts, err := parseFlags(args)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	if len(args) < 1 {
+		fmt.Fprintf(w, "no <version> specified\n")
+		return
+	}
+	v, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		fmt.Fprintf(w, "invalid version %q\n", args[0])
+		return
+	}
+
+	// commitConfig locks the config to write it.
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+	checkoutVersion(w, r, c, v, opts.Has("yes"))
+}
+
+func checkoutVersion(w io.Writer, r io.Reader, c *restrictions.Config, v int64, yes bool) {
+	entries, err := restrictions.ReadHistory()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	e, err := restrictions.LookupVersion(entries, v)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	// the restrictions and the settings are restored, while the state
+	// of the commits, the lock and the allowances stay as they are.
+	restored := e.Config
+	restored.LastCommited = c.LastCommited
+	restored.Version = c.Version
+	restored.Lock = c.Lock
+	restored.Allowances = c.Allowances
+	restored.AllowanceBudget = c.AllowanceBudget
+
+	if err := restrictions.CheckLock(c, time.Now()); err != nil && restrictions.Loosens(c, &restored) {
+		fmt.Fprintf(w, "%v, refusing to check out version %v which lifts restrictions\n", err, v)
+		return
+	}
+
+	fmt.Fprintf(w, "checking out version %v\n", v)
+	commitConfig(w, r, &restored, commitOptions{Yes: yes})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+func lock(w io.Writer, args ...string) {
+	_, opts, err := parseFlags(args, "until", "for")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	now := time.Now()
+	until, locked, err := restrictions.LockedUntil(c, now)
+	if err != nil {
+		fmt.Fprintf(w, "%v, treated as locked\n", err)
+		return
+	}
+
+	if !opts.Has("until") && !opts.Has("for") {
+		if !locked {
+			fmt.Fprintf(w, "not locked\n")
+			return
+		}
+		fmt.Fprintf(w, "locked until %s\n", until.Format(time.DateTime))
+		return
+	}
+
+	deadline, err := parseDeadline(opts, now)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	if locked && until.After(deadline) {
+		fmt.Fprintf(w, "already locked until %s, a lock can't be shortened\n", until.Format(time.DateTime))
+		return
+	}
+
+	c.Lock = &restrictions.Lock{Until: deadline}
+	if err := restrictions.WriteConfig(c); err != nil {
+		fmt.Fprintf(w, "failed to update config: %v\n", err)
+		return
+	}
+
+	if err := restrictions.StoreLock(*c.Lock); err != nil {
+		fmt.Fprintf(w, "locked until %s, commit the restrictions to make the lock permanent\n", deadline.Format(time.DateTime))
+		return
+	}
+	fmt.Fprintf(w, "locked until %s\n", deadline.Format(time.DateTime))
+}
+
+// parseDeadline returns the deadline described by either the --until
+// or the --for option. A time of day that already passed refers to
+// the next day.
+func parseDeadline(opts flags, now time.Time) (time.Time, error) {
+	if opts.Has("for") {
+		d, err := time.ParseDuration(opts["for"])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid duration %q: %w", opts["for"], err)
+		}
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("duration %q must be positive", opts["for"])
+		}
+		return now.Add(d), nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, opts["until"], now.Location()); err == nil {
+			if !t.After(now) {
+				return time.Time{}, fmt.Errorf("deadline %q already passed", opts["until"])
+			}
+			return t, nil
+		}
+	}
+
+	t, err := time.ParseInLocation("15:04", opts["until"], now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline %q, expected HH:MM, 2006-01-02 15:04 or RFC3339", opts["until"])
+	}
+	deadline := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !deadline.After(now) {
+		deadline = deadline.AddDate(0, 0, 1)
+	}
+	return deadline, nil
+}
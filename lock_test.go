@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDeadline(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 30, 0, 0, time.Local)
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, time.October, day, hour, min, 0, 0, time.Local)
+	}
+
+	tests := []struct {
+		opts  flags
+		want  time.Time
+		valid bool
+	}{
+		{opts: flags{"for": "90m"}, want: at(17, 14, 0), valid: true},
+		{opts: flags{"for": "0s"}},
+		{opts: flags{"for": "-1h"}},
+		{opts: flags{"for": "1 hour"}},
+		{opts: flags{"for": "1h", "until": "18:00"}, want: at(17, 13, 30), valid: true},
+		{opts: flags{"until": "18:00"}, want: at(17, 18, 0), valid: true},
+		// a time of day that already passed is the next day.
+		{opts: flags{"until": "09:00"}, want: at(18, 9, 0), valid: true},
+		{opts: flags{"until": "12:30"}, want: at(18, 12, 30), valid: true},
+		{opts: flags{"until": "2026-10-20 08:15"}, want: at(20, 8, 15), valid: true},
+		{opts: flags{"until": "2026-10-20"}, want: at(20, 0, 0), valid: true},
+		{opts: flags{"until": now.Add(time.Hour).Format(time.RFC3339)}, want: now.Add(time.Hour), valid: true},
+		{opts: flags{"until": "2026-10-16 08:15"}},
+		{opts: flags{"until": "2026-10-17"}},
+		{opts: flags{"until": now.Format(time.RFC3339)}},
+		{opts: flags{"until": "25:00"}},
+		{opts: flags{"until": "tomorrow"}},
+		{opts: flags{"until": ""}},
+	}
+	for _, tt := range tests {
+		got, err := parseDeadline(tt.opts, now)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseDeadline(%v) error = %v, want valid %v", tt.opts, err, tt.valid)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseDeadline(%v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/user"
@@ -10,41 +11,93 @@ import (
 )
 
 func main() {
-	if err := run(); err != nil {
+	code, err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
 		log.Fatal(err)
 	}
+	os.Exit(code)
 }
 
-func run() error {
+// run runs the command given by the arguments, without the program
+// name, and returns the exit code.
+func run(args []string, in io.Reader, out io.Writer) (int, error) {
 	// cache the user info.
 	if _, err := user.Current(); err != nil {
-		return fmt.Errorf("failed to retrieve current user: %w", err)
+		return exitFailure, fmt.Errorf("failed to retrieve current user: %w", err)
+	}
+
+	// the privileged helper, run by an unprivileged dnd through sudo
+	// or pkexec, only ever changes the real system and never touches
+	// the config of the user, neither --root nor DND_ROOT apply.
+	if len(args) > 0 && args[0] == "helper" {
+		return exitOK, restrictions.RunHelper(in, out)
+	}
+
+	// every path is rebased onto the root, if any.
+	args, root, err := extractOption(args, "root")
+	if err != nil {
+		return exitFailure, err
+	}
+	if root == "" {
+		root = os.Getenv("DND_ROOT")
+	}
+	if err := restrictions.SetRoot(root); err != nil {
+		return exitFailure, err
+	}
+	// the service managers of the system are not meant for a fake tree.
+	if root != "" {
+		restrictions.SetExecutor(new(restrictions.Recorder))
 	}
 
 	if err := restrictions.CreateConfigDir(); err != nil {
-		return fmt.Errorf("failed to create directory for storing configuration: %w", err)
+		return exitFailure, fmt.Errorf("failed to create directory for storing configuration: %w", err)
 	}
 
-	args := os.Args[1:]
 	if len(args) < 1 {
-		help(os.Stdout)
-		return nil
+		help(out)
+		return exitOK, nil
 	}
 
 	switch args[0] {
 	case "add":
-		add(os.Stdout, os.Stdin, args[1:]...)
+		add(out, in, args[1:]...)
 	case "del":
-		del(os.Stdout, args[1:]...)
+		del(out, args[1:]...)
 	case "print":
-		print(os.Stdout)
+		print(out, args[1:]...)
 	case "types":
-		types(os.Stdout)
+		types(out, args[1:]...)
 	case "commit":
-		commit(os.Stdout, os.Stdin)
+		return commit(out, in, args[1:]...), nil
+	case "status":
+		return status(out, args[1:]...), nil
+	case "profile":
+		profile(out, in, args[1:]...)
+	case "allow":
+		allow(out, args[1:]...)
+	case "subscribe":
+		subscribe(out, args[1:]...)
+	case "unsubscribe":
+		unsubscribe(out, args[1:]...)
+	case "sinkhole":
+		setSinkhole(out, args[1:]...)
+	case "history":
+		history(out)
+	case "diff":
+		diffVersions(out, args[1:]...)
+	case "undo":
+		undo(out, in, args[1:]...)
+	case "checkout":
+		checkout(out, in, args[1:]...)
+	case "restore":
+		restore(out, args[1:]...)
+	case "lock":
+		lock(out, args[1:]...)
+	case "daemon":
+		daemon(out)
 	default:
-		help(os.Stdout)
+		help(out)
 	}
 
-	return nil
+	return exitOK, nil
 }
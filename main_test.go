@@ -0,0 +1,167 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+// testRoot returns a directory to run dnd in with --root.
+func testRoot(t *testing.T) string {
+	t.Setenv("HOME", "/home/dnd")
+	t.Setenv("DND_ROOT", "")
+	t.Setenv("SUDO_UID", "")
+	t.Setenv("PKEXEC_UID", "")
+	previous := restrictions.SetExecutor(new(restrictions.Recorder))
+	t.Cleanup(func() {
+		restrictions.SetExecutor(previous)
+		restrictions.SetRoot("")
+	})
+	return t.TempDir()
+}
+
+// dnd runs dnd in the root with the input and returns its output.
+func dnd(t *testing.T, dir, in string, args ...string) string {
+	out := new(strings.Builder)
+	args = append([]string{"--root", dir}, args...)
+	if code, err := run(args, strings.NewReader(in), out); err != nil || code != exitOK {
+		t.Fatalf("dnd %v exited with %v: %v\n%s", args, code, err, out)
+	}
+	return out.String()
+}
+
+func TestCommitRoot(t *testing.T) {
+	dir := testRoot(t)
+
+	const app = "dnd-test-no-such-application"
+	dnd(t, dir, "", "add", "domain", "example.com,Example.org")
+	// no application is installed in the root, the general
+	// purpose pattern is the only candidate.
+	dnd(t, dir, "1\n", "add", "application", app)
+	dnd(t, dir, "", "commit", "--yes")
+
+	if restrictions.Root() != dir {
+		t.Fatalf("Root() = %q, want %q", restrictions.Root(), dir)
+	}
+
+	hosts, err := os.ReadFile(filepath.Join(dir, "etc", "hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, domain := range []string{"example.com", "example.org"} {
+		if !strings.Contains(string(hosts), "127.0.0.1 "+domain+"\n") {
+			t.Errorf("%s not blocked in the hosts file of the root:\n%s", domain, hosts)
+		}
+	}
+
+	apps, err := restrictions.SyncApplications()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apps) != 1 || apps[0].Pattern != app {
+		t.Errorf("applications in the root = %+v, want %s", apps, app)
+	}
+
+	if !strings.HasPrefix(restrictions.ConfigPath(), filepath.Join(dir, "home", "dnd")) {
+		t.Errorf("config %s is not in the root", restrictions.ConfigPath())
+	}
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.LastCommited == nil {
+		t.Fatal("commit not recorded in the config")
+	}
+	if got := c.LastCommited.Restrictions[restrictions.Domain].Items(); !slices.Equal(got, []string{"example.com", "example.org"}) {
+		t.Errorf("commited domains = %v", got)
+	}
+	if got := c.LastCommited.Restrictions[restrictions.Application].Items(); !slices.Equal(got, []string{app}) {
+		t.Errorf("commited applications = %v", got)
+	}
+}
+
+func TestCheckoutLocked(t *testing.T) {
+	dir := testRoot(t)
+
+	dnd(t, dir, "", "lock", "--for", "1h")
+	dnd(t, dir, "", "add", "domain", "example.com")
+	dnd(t, dir, "", "add", "domain", "example.org")
+
+	for _, args := range [][]string{{"undo", "--yes"}, {"checkout", "1", "--yes"}} {
+		out := dnd(t, dir, "", args...)
+		if !strings.Contains(out, "refusing to check out") {
+			t.Errorf("dnd %v while locked = %q, want refused", args, out)
+		}
+		c, err := restrictions.ReadConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Restrictions[restrictions.Domain].Items(); !slices.Equal(got, []string{"example.com", "example.org"}) {
+			t.Errorf("domains after dnd %v while locked = %v", args, got)
+		}
+	}
+}
+
+// conflictingReader changes the config the first time the prompt is
+// answered, as another dnd invocation would, and then confirms.
+type conflictingReader struct {
+	change  func()
+	changed bool
+}
+
+func (r *conflictingReader) Read(p []byte) (int, error) {
+	if !r.changed {
+		r.changed = true
+		r.change()
+	}
+	return copy(p, "yes\n"), nil
+}
+
+func TestCommitConflict(t *testing.T) {
+	dir := testRoot(t)
+
+	dnd(t, dir, "", "add", "domain", "example.com")
+	in := &conflictingReader{change: func() { dnd(t, dir, "", "add", "domain", "example.org") }}
+	out := new(strings.Builder)
+	if code, err := run([]string{"--root", dir, "commit", "domain"}, in, out); err != nil || code != exitOK {
+		t.Fatalf("dnd commit exited with %v: %v\n%s", code, err, out)
+	}
+	if !strings.Contains(out.String(), "only the commit was recorded") {
+		t.Errorf("dnd commit = %q, want the conflict reported", out)
+	}
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Restrictions[restrictions.Domain].Items(); !slices.Equal(got, []string{"example.com", "example.org"}) {
+		t.Errorf("domains = %v, want the change made during the commit kept", got)
+	}
+	if c.LastCommited == nil {
+		t.Fatal("commit not recorded in the config")
+	}
+	if got := c.LastCommited.Restrictions[restrictions.Domain].Items(); !slices.Equal(got, []string{"example.com"}) {
+		t.Errorf("commited domains = %v, want example.com", got)
+	}
+	if r := c.LastCommited.Results[restrictions.Domain]["example.com"]; r.Outcome != restrictions.OutcomeApplied {
+		t.Errorf("result of example.com = %+v, want applied", r)
+	}
+}
+
+func TestStatusExitCode(t *testing.T) {
+	dir := testRoot(t)
+
+	dnd(t, dir, "", "add", "domain", "example.com")
+	out := new(strings.Builder)
+	if code, err := run([]string{"--root", dir, "status"}, strings.NewReader(""), out); err != nil || code != exitPending {
+		t.Errorf("dnd status with pending changes exited with %v, %v, want %v\n%s", code, err, exitPending, out)
+	}
+	dnd(t, dir, "", "commit", "--yes")
+	dnd(t, dir, "", "status")
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+// schemaVersion of the machine readable output, bumped on
+// incompatible changes. Adding fields is not such a change.
+const schemaVersion = 1
+
+// Formats accepted by the --output option.
+const (
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputTable = "table"
+)
+
+// parseOutput returns the format requested with --output,
+// or an empty string for the default human readable output.
+func parseOutput(opts flags) (string, error) {
+	if !opts.Has("output") {
+		return "", nil
+	}
+	switch f := strings.ToLower(opts["output"]); f {
+	case outputJSON, outputYAML, outputTable:
+		return f, nil
+	default:
+		return "", fmt.Errorf("invalid output %q, expected one of json, yaml or table", opts["output"])
+	}
+}
+
+// tabular is implemented by the outputs that can be printed as a table.
+type tabular interface {
+	table(w io.Writer)
+}
+
+// encode writes v in the given format.
+func encode(w io.Writer, format string, v tabular) error {
+	switch format {
+	case outputJSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(v)
+	case outputYAML:
+		e := yaml.NewEncoder(w)
+		e.SetIndent(2)
+		if err := e.Encode(v); err != nil {
+			return err
+		}
+		return e.Close()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		v.table(tw)
+		return tw.Flush()
+	}
+}
+
+// typeName is the name of the type used in the machine readable output.
+func typeName(t restrictions.Type) string { return strings.ToLower(t.String()) }
+
+// restrictionOutput is a single configured item.
+type restrictionOutput struct {
+	Type string `json:"type" yaml:"type"`
+	Item string `json:"item" yaml:"item"`
+	// Schedule is empty for items enforced at all times.
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	// Expands lists the domains blocked for a wildcard domain.
+	Expands []string `json:"expands,omitempty" yaml:"expands,omitempty"`
+}
+
+type allowanceOutput struct {
+	Type  string    `json:"type" yaml:"type"`
+	Item  string    `json:"item" yaml:"item"`
+	Until time.Time `json:"until" yaml:"until"`
+}
+
+type subscriptionOutput struct {
+	Name      string    `json:"name" yaml:"name"`
+	Source    string    `json:"source" yaml:"source"`
+	Refreshed time.Time `json:"refreshed" yaml:"refreshed"`
+}
+
+// failedOutput is an item that failed at the last commit.
+type failedOutput struct {
+	Type  string `json:"type" yaml:"type"`
+	Item  string `json:"item" yaml:"item"`
+	Error string `json:"error" yaml:"error"`
+}
+
+// failedItems returns the items that failed at the last commit.
+func failedItems(c *restrictions.Config) []failedOutput {
+	out := []failedOutput{}
+	for _, k := range restrictions.Kinds() {
+		failed := c.Failed(k.Type)
+		for _, item := range slices.Sorted(maps.Keys(failed)) {
+			out = append(out, failedOutput{Type: typeName(k.Type), Item: item, Error: failed[item]})
+		}
+	}
+	return out
+}
+
+type sinkholeOutput struct {
+	IPv4 string `json:"ipv4" yaml:"ipv4"`
+	IPv6 string `json:"ipv6" yaml:"ipv6"`
+}
+
+// printOutput is the output of `dnd print`.
+type printOutput struct {
+	SchemaVersion int    `json:"schema_version" yaml:"schema_version"`
+	Profile       string `json:"profile" yaml:"profile"`
+	// Restrictions are the configured restrictions of the profile.
+	Restrictions []restrictionOutput `json:"restrictions" yaml:"restrictions"`
+	// Commited are the restrictions of the last commit,
+	// only present for the profile in use.
+	Commited []restrictionOutput `json:"commited" yaml:"commited"`
+	// Failed are the items that failed at the last
+	// commit, only present for the profile in use.
+	Failed        []failedOutput       `json:"failed" yaml:"failed"`
+	Allowances    []allowanceOutput    `json:"allowances" yaml:"allowances"`
+	Subscriptions []subscriptionOutput `json:"subscriptions" yaml:"subscriptions"`
+	Sinkhole      sinkholeOutput       `json:"sinkhole" yaml:"sinkhole"`
+	// LockedUntil is only present while a lock is active.
+	LockedUntil *time.Time `json:"locked_until,omitempty" yaml:"locked_until,omitempty"`
+}
+
+func profileOutput(p *restrictions.Profile) []restrictionOutput {
+	out := []restrictionOutput{}
+	for _, k := range restrictions.Kinds() {
+		for _, item := range p.Restrictions[k.Type].Items() {
+			r := restrictionOutput{
+				Type:     typeName(k.Type),
+				Item:     item,
+				Schedule: string(p.Schedules[k.Type][item]),
+			}
+			if k.Type == restrictions.Domain {
+				if expanded := restrictions.ExpandDomain(item); len(expanded) > 1 {
+					r.Expands = expanded
+				}
+			}
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func newPrintOutput(c *restrictions.Config, name string, now time.Time) (printOutput, error) {
+	p, err := c.Lookup(name)
+	if err != nil {
+		return printOutput{}, err
+	}
+	if name == "" {
+		name = c.CurrentProfile()
+	}
+
+	out := printOutput{
+		SchemaVersion: schemaVersion,
+		Profile:       name,
+		Restrictions:  profileOutput(p),
+		Commited:      []restrictionOutput{},
+		Failed:        []failedOutput{},
+		Allowances:    []allowanceOutput{},
+		Subscriptions: []subscriptionOutput{},
+	}
+	if c.LastCommited != nil && name == c.CurrentProfile() {
+		out.Commited = profileOutput(&c.LastCommited.Profile)
+		out.Failed = failedItems(c)
+	}
+	for _, a := range c.ActiveAllowances(now) {
+		out.Allowances = append(out.Allowances, allowanceOutput{Type: typeName(a.Type), Item: a.Item, Until: a.Until})
+	}
+	for _, s := range slices.Sorted(maps.Keys(c.Subscriptions)) {
+		out.Subscriptions = append(out.Subscriptions, subscriptionOutput{Name: s, Source: c.Subscriptions[s].Source, Refreshed: c.Subscriptions[s].Refreshed})
+	}
+	sinkhole := c.Sinkhole.OrDefault()
+	out.Sinkhole = sinkholeOutput{IPv4: sinkhole.IPv4, IPv6: sinkhole.IPv6}
+	if until, locked, err := restrictions.LockedUntil(c, now); err != nil {
+		return out, err
+	} else if locked {
+		out.LockedUntil = &until
+	}
+	return out, nil
+}
+
+func (p printOutput) table(w io.Writer) {
+	fmt.Fprintf(w, "TYPE\tITEM\tSCHEDULE\tCOMMITED\n")
+	for _, r := range p.Restrictions {
+		schedule := r.Schedule
+		if schedule == "" {
+			schedule = "always"
+		}
+		commited := "no"
+		if slices.ContainsFunc(p.Commited, func(o restrictionOutput) bool { return o.Type == r.Type && o.Item == r.Item }) {
+			commited = "yes"
+		}
+		if slices.ContainsFunc(p.Failed, func(o failedOutput) bool { return o.Type == r.Type && o.Item == r.Item }) {
+			commited = "failed"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Type, r.Item, schedule, commited)
+	}
+}
+
+type typeOutput struct {
+	Name string `json:"name" yaml:"name"`
+	Help string `json:"help" yaml:"help"`
+}
+
+// typesOutput is the output of `dnd types`.
+type typesOutput struct {
+	SchemaVersion int          `json:"schema_version" yaml:"schema_version"`
+	Types         []typeOutput `json:"types" yaml:"types"`
+}
+
+func newTypesOutput() typesOutput {
+	out := typesOutput{SchemaVersion: schemaVersion}
+	for _, k := range restrictions.Kinds() {
+		out.Types = append(out.Types, typeOutput{Name: typeName(k.Type), Help: k.Help})
+	}
+	return out
+}
+
+func (t typesOutput) table(w io.Writer) {
+	fmt.Fprintf(w, "NAME\tHELP\n")
+	for _, k := range t.Types {
+		help, _, _ := strings.Cut(k.Help, "\n")
+		fmt.Fprintf(w, "%s\t%s\n", k.Name, help)
+	}
+}
+
+// diffEntryOutput is a single restriction at the OS level.
+type diffEntryOutput struct {
+	Key   string   `json:"key" yaml:"key"`
+	Items []string `json:"items" yaml:"items"`
+}
+
+type scheduledOutput struct {
+	Item     string `json:"item" yaml:"item"`
+	Schedule string `json:"schedule" yaml:"schedule"`
+	Active   bool   `json:"active" yaml:"active"`
+}
+
+// diffOutput is the difference between the configured and the
+// actual restrictions of a single type.
+type diffOutput struct {
+	Type    string            `json:"type" yaml:"type"`
+	Matched []diffEntryOutput `json:"matched" yaml:"matched"`
+	Add     []diffEntryOutput `json:"add" yaml:"add"`
+	Delete  []diffEntryOutput `json:"delete" yaml:"delete"`
+	// Upgraded counts the deleted restrictions that are
+	// added back with updated contents.
+	Upgraded  int               `json:"upgraded" yaml:"upgraded"`
+	Scheduled []scheduledOutput `json:"scheduled" yaml:"scheduled"`
+	// Messages are the warnings and errors encountered for the type.
+	Messages []string `json:"messages" yaml:"messages"`
+}
+
+func entriesOutput(rs []restrictions.Restriction) []diffEntryOutput {
+	out := []diffEntryOutput{}
+	for _, r := range rs {
+		out = append(out, diffEntryOutput{Key: strings.TrimSpace(r.Key()), Items: r.Items()})
+	}
+	return out
+}
+
+func newDiffOutput(d restrictions.Diff, messages string) diffOutput {
+	out := diffOutput{
+		Type:      typeName(d.Type),
+		Matched:   entriesOutput(d.Matched),
+		Add:       entriesOutput(d.Missing),
+		Delete:    entriesOutput(d.Delete),
+		Upgraded:  len(d.Delete) - len(d.Removed()),
+		Scheduled: []scheduledOutput{},
+		Messages:  []string{},
+	}
+	for _, s := range d.Scheduled {
+		out.Scheduled = append(out.Scheduled, scheduledOutput{Item: s.Item, Schedule: string(s.Schedule), Active: s.Active})
+	}
+	for _, line := range strings.Split(messages, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out.Messages = append(out.Messages, line)
+		}
+	}
+	return out
+}
+
+// commitOutput is the output of `dnd commit --dry-run`.
+type commitOutput struct {
+	SchemaVersion int `json:"schema_version" yaml:"schema_version"`
+	// Pending reports whether any type has changes to commit.
+	Pending bool         `json:"pending" yaml:"pending"`
+	Diffs   []diffOutput `json:"diffs" yaml:"diffs"`
+}
+
+func (c commitOutput) table(w io.Writer) {
+	fmt.Fprintf(w, "TYPE\tACTION\tITEMS\n")
+	for _, d := range c.Diffs {
+		for _, action := range []struct {
+			name    string
+			entries []diffEntryOutput
+		}{{"matched", d.Matched}, {"add", d.Add}, {"delete", d.Delete}} {
+			for _, e := range action.entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", d.Type, action.name, summarize(e.Items))
+			}
+		}
+		for _, s := range d.Scheduled {
+			status := "inactive"
+			if s.Active {
+				status = "active"
+			}
+			fmt.Fprintf(w, "%s\tscheduled\t%s %s (%s)\n", d.Type, s.Item, status, s.Schedule)
+		}
+		for _, m := range d.Messages {
+			fmt.Fprintf(w, "%s\tmessage\t%s\n", d.Type, m)
+		}
+	}
+}
+
+// summarize joins the items, eliding all but the first few.
+func summarize(items []string) string {
+	const shown = 3
+	if len(items) <= shown {
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprintf("%s,... (%v more)", strings.Join(items[:shown], ","), len(items)-shown)
+}
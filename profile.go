@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+var ProfileUsage = `profiles are named sets of restrictions, only the profile in use is commited.
+
+subcommands:
+	:list                   Lists all profiles, the one in use is marked with '*'.
+	:create <name>          Creates a new empty profile.
+	:copy   <src> <dst>     Creates a new profile with the restrictions of an existing one.
+	:delete <name>          Deletes a profile that is not in use.
+	:use    <name>          Switches to the profile and commits its restrictions.
+`
+
+func profile(w io.Writer, r io.Reader, args ...string) {
+	if len(args) < 1 {
+		fmt.Fprintln(w, ProfileUsage)
+		return
+	}
+
+	// switching profiles commits, commitConfig locks the config to write it.
+	if args[0] != "use" {
+		unlock, err := restrictions.LockConfig()
+		if err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		defer unlock()
+	}
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	switch args[0] {
+	case "list":
+		for _, name := range c.ProfileNames() {
+			marker := " "
+			if name == c.CurrentProfile() {
+				marker = "*"
+			}
+			fmt.Fprintf(w, "%s %s\n", marker, name)
+		}
+		return
+	case "create":
+		if len(args) < 2 {
+			fmt.Fprintf(w, "no <name> specified\n")
+			return
+		}
+		err = c.CreateProfile(args[1])
+	case "copy":
+		if len(args) < 3 {
+			fmt.Fprintf(w, "no <src> and <dst> specified\n")
+			return
+		}
+		err = c.CopyProfile(args[1], args[2])
+	case "delete":
+		if len(args) < 2 {
+			fmt.Fprintf(w, "no <name> specified\n")
+			return
+		}
+		err = c.DeleteProfile(args[1])
+	case "use":
+		if len(args) < 2 {
+			fmt.Fprintf(w, "no <name> specified\n")
+			return
+		}
+		previous := *c
+		if err := c.UseProfile(args[1]); err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		if err := restrictions.CheckLock(&previous, time.Now()); err != nil && restrictions.Loosens(&previous, c) {
+			fmt.Fprintf(w, "%v, refusing to switch to profile %q which lifts restrictions of the profile in use\n", err, args[1])
+			return
+		}
+		fmt.Fprintf(w, "switched to profile %q\n", c.CurrentProfile())
+		commitConfig(w, r, c, commitOptions{})
+		return
+	default:
+		fmt.Fprintln(w, ProfileUsage)
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	if err := restrictions.WriteConfig(c); err != nil {
+		fmt.Fprintf(w, "failed to update config: %v\n", err)
+		return
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+// restore rolls the hosts file and the application restrictions
+// back to a backup taken by a previous commit.
+func restore(w io.Writer, args ...string) {
+	args, opts, err := parseFlags(args)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	backups, err := restrictions.Backups()
+	if err != nil {
+		fmt.Fprintf(w, "failed to list backups in %s: %v\n", restrictions.BackupsDir(), err)
+		return
+	}
+
+	if opts.Has("list") || len(args) < 1 {
+		if len(backups) == 0 {
+			fmt.Fprintf(w, "no backups\n")
+			return
+		}
+		for _, b := range backups {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", b.ID, b.Time.Local().Format(time.DateTime), strings.Join(b.Files, ","))
+		}
+		return
+	}
+
+	if !slices.ContainsFunc(backups, func(b restrictions.Backup) bool { return b.ID == args[0] }) {
+		fmt.Fprintf(w, "%v: %s\n", restrictions.ErrBackupNotFound, args[0])
+		return
+	}
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	now := time.Now()
+	// restoring may remove commited restrictions.
+	if err := restrictions.CheckLock(c, now); err != nil {
+		fmt.Fprintf(w, "%v, refusing to restore %s\n", err, args[0])
+		return
+	}
+
+	done, err := restrictions.MarkApplying()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer done()
+
+	// the current state is backed up too, so that the restore can be undone.
+	b, err := restrictions.CreateBackup(now)
+	if err != nil {
+		fmt.Fprintf(w, "failed to back up the current state: %v, aborting...\n", err)
+		return
+	}
+	if err := restrictions.PruneBackups(); err != nil {
+		fmt.Fprintf(w, "failed to delete old backups: %v\n", err)
+	}
+
+	if err := restrictions.RestoreBackup(args[0]); err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		fmt.Fprintf(w, "the state before the restore was backed up as %s\n", b.ID)
+		return
+	}
+	fmt.Fprintf(w, "restored backup %s, the state before the restore was backed up as %s\n", args[0], b.ID)
+}
@@ -0,0 +1,148 @@
+package restrictions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// DefaultAllowanceBudget is the number of allowances
+// that can be granted per day if not configured otherwise.
+const DefaultAllowanceBudget = 3
+
+// ErrBudgetExhausted is returned when no more allowances can be granted today.
+var ErrBudgetExhausted = errors.New("daily allowance budget exhausted")
+
+// Allowance temporarily lifts a restriction, once
+// it expires the restriction is in effect again.
+type Allowance struct {
+	Type  Type
+	Item  string
+	From  time.Time
+	Until time.Time
+}
+
+func (a Allowance) Active(now time.Time) bool { return !now.Before(a.From) && now.Before(a.Until) }
+
+// Active returns the restrictions of the profile in use that are
+// in effect at the given time, excluding the ones currently allowed.
+func (c *Config) Active(now time.Time) map[Type]List {
+	active := c.Profile.Active(now)
+	for _, a := range c.Allowances {
+		if !a.Active(now) {
+			continue
+		}
+		l, ok := active[a.Type]
+		if !ok {
+			continue
+		}
+		for {
+			n, deleted := l.Remove(a.Item)
+			if !deleted {
+				break
+			}
+			l = n
+		}
+		if l.Empty() {
+			delete(active, a.Type)
+		} else {
+			active[a.Type] = l
+		}
+	}
+	return active
+}
+
+// ActiveAllowances returns the allowances in effect at the given time.
+func (c *Config) ActiveAllowances(now time.Time) []Allowance {
+	var active []Allowance
+	for _, a := range c.Allowances {
+		if a.Active(now) {
+			active = append(active, a)
+		}
+	}
+	return active
+}
+
+// Allow grants the allowance if the daily budget permits it.
+// Allowances granted before today are forgotten.
+func (c *Config) Allow(a Allowance) error {
+	y, m, d := a.From.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, a.From.Location())
+	c.Allowances = slices.DeleteFunc(c.Allowances, func(a Allowance) bool { return a.From.Before(today) && !a.Until.After(today) })
+
+	budget := c.AllowanceBudget
+	if budget == 0 {
+		budget = DefaultAllowanceBudget
+	}
+
+	granted := 0
+	for _, a := range c.Allowances {
+		if !a.From.Before(today) {
+			granted++
+		}
+	}
+	if granted >= budget {
+		return fmt.Errorf("%w: %v/%v allowances granted today", ErrBudgetExhausted, granted, budget)
+	}
+
+	c.Allowances = append(c.Allowances, a)
+	return nil
+}
+
+// AuditEntry is a single record of the audit trail.
+type AuditEntry struct {
+	Time   time.Time
+	User   string
+	Action string
+	Type   string
+	Item   string
+	Until  time.Time
+}
+
+func AuditPath() string { return filepath.Join(filepath.Dir(ConfigPath()), "audit.log") }
+
+// invokingUser returns the name of the user running the program,
+// or of the user that elevated it with sudo or pkexec.
+func invokingUser() string {
+	if u := invoker(); u != nil {
+		return u.Username
+	}
+	if u := os.Getenv("SUDO_USER"); u != "" {
+		return u
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// Audit appends the entry to the audit trail.
+func Audit(e AuditEntry) error {
+	if e.User == "" {
+		e.User = invokingUser()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(AuditPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := chownToInvokingUser(AuditPath()); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
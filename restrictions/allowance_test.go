@@ -0,0 +1,57 @@
+package restrictions
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAllowBudget(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.Local)
+	allowance := func(from time.Time, d time.Duration) Allowance {
+		return Allowance{Type: Domain, Item: "example.com", From: from, Until: from.Add(d)}
+	}
+
+	tests := []struct {
+		name    string
+		budget  int
+		granted []Allowance
+		want    error
+		kept    int
+	}{
+		{name: "none granted", kept: 1},
+		{name: "default budget left", granted: []Allowance{allowance(now.Add(-2*time.Hour), time.Minute), allowance(now.Add(-time.Hour), time.Minute)}, kept: 3},
+		{name: "default budget exhausted", granted: []Allowance{allowance(now.Add(-3*time.Hour), time.Minute), allowance(now.Add(-2*time.Hour), time.Minute), allowance(now.Add(-time.Hour), time.Minute)}, want: ErrBudgetExhausted, kept: 3},
+		{name: "configured budget exhausted", budget: 1, granted: []Allowance{allowance(now.Add(-time.Hour), time.Minute)}, want: ErrBudgetExhausted, kept: 1},
+		{name: "configured budget left", budget: 5, granted: []Allowance{allowance(now.Add(-3*time.Hour), time.Minute), allowance(now.Add(-2*time.Hour), time.Minute), allowance(now.Add(-time.Hour), time.Minute)}, kept: 4},
+		{name: "yesterday forgotten", budget: 1, granted: []Allowance{allowance(now.AddDate(0, 0, -1), time.Minute)}, kept: 1},
+		// still in effect, but granted yesterday.
+		{name: "over midnight kept", budget: 1, granted: []Allowance{allowance(now.Add(-13*time.Hour), 14*time.Hour)}, kept: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Config{AllowanceBudget: tt.budget, Allowances: tt.granted}
+			if err := c.Allow(allowance(now, 15*time.Minute)); !errors.Is(err, tt.want) {
+				t.Fatalf("Allow() error = %v, want %v", err, tt.want)
+			}
+			if len(c.Allowances) != tt.kept {
+				t.Errorf("Allow() kept %v allowances, want %v: %+v", len(c.Allowances), tt.kept, c.Allowances)
+			}
+		})
+	}
+}
+
+func TestActiveAllowed(t *testing.T) {
+	now := time.Date(2026, time.October, 17, 12, 0, 0, 0, time.Local)
+	c := &Config{Profile: Profile{Restrictions: map[Type]List{Domain: "example.com,example.org"}}}
+	if err := c.Allow(Allowance{Type: Domain, Item: "example.com", From: now, Until: now.Add(15 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := c.Active(now.Add(time.Minute))[Domain]; got != "example.org" {
+		t.Errorf("Active() while allowed = %q, want example.org", got)
+	}
+	if got := c.Active(now.Add(15 * time.Minute))[Domain]; got != "example.com,example.org" {
+		t.Errorf("Active() once expired = %q, want both", got)
+	}
+}
@@ -1,14 +1,15 @@
-package main
+package restrictions
 
 import (
 	"cmp"
-	"io"
+	"fmt"
 	"maps"
 	"os"
 	"path/filepath"
 	"runtime"
 	"slices"
 	"strings"
+	"unicode"
 )
 
 type MatchSet = map[Match]struct{}
@@ -19,15 +20,56 @@ type Match struct {
 	score   int
 }
 
+func init() {
+	Register(Kind{
+		Type: Application,
+		Name: "Application",
+		Help: "A single application name or a list of applications names separated with ',' [spotify, chrome]",
+		Parse: func(entry string) ([]string, error) {
+			pattern := strings.TrimSpace(entry)
+			if strings.ContainsFunc(pattern, unicode.IsControl) {
+				return nil, fmt.Errorf("invalid application pattern %q: control characters are not allowed", entry)
+			}
+			return []string{pattern}, nil
+		},
+		Candidates: func(item string) []Candidate {
+			var candidates []Candidate
+			for _, m := range FindApplicationBasedOnPattern(item) {
+				candidates = append(candidates, Candidate{
+					Item:        filepath.Join(m.dir, m.program),
+					Description: fmt.Sprintf("%s | %s", m.program, m.dir),
+				})
+			}
+			return candidates
+		},
+		Sync: func() ([]Restriction, error) { r, err := SyncApplications(); return toRestrictions(r), err },
+		New:  func(_ *Config, item string) Restriction { return NewApplication(item) },
+		Watch: func() []string {
+			if applicationsDir() == "" {
+				return nil
+			}
+			return []string{applicationsDir()}
+		},
+		Backup:  backupApplications,
+		Restore: restoreApplications,
+	})
+}
+
+// pkillCommand returns the command run periodically to kill
+// the processes matching the pattern, case insensitive.
+func pkillCommand(pattern string) []string {
+	return []string{"pkill", "-SIGKILL", "-i", "-f", pattern}
+}
+
 // FindApplicationBasedOnPattern will look at the PATH environment
 // and inside /Applications directory for a application that matches
-// the pattern.
-func FindApplicationBasedOnPattern(out io.Writer, pattern string) []Match {
+// the pattern. The directories are rebased onto the root, if any.
+func FindApplicationBasedOnPattern(pattern string) []Match {
 	matches := make(MatchSet)
 
 	// TODO: find a way to search more broadly for apps.
 	if runtime.GOOS == "darwin" {
-		apps, err := patternMatch("/Applications", pattern)
+		apps, err := patternMatch(rooted("/Applications"), pattern)
 		if err != nil {
 			apps = []Match{}
 		}
@@ -37,7 +79,7 @@ func FindApplicationBasedOnPattern(out io.Writer, pattern string) []Match {
 	}
 
 	if val, ok := os.LookupEnv("GOPATH"); ok {
-		bins, err := patternMatch(filepath.Join(val, "bin"), pattern)
+		bins, err := patternMatch(rooted(filepath.Join(val, "bin")), pattern)
 		if err != nil {
 			bins = []Match{}
 		}
@@ -52,7 +94,7 @@ func FindApplicationBasedOnPattern(out io.Writer, pattern string) []Match {
 			separator = ";"
 		}
 		for _, path := range strings.Split(val, separator) {
-			bins, err := patternMatch(path, pattern)
+			bins, err := patternMatch(rooted(path), pattern)
 			if err != nil {
 				bins = []Match{}
 			}
@@ -11,7 +11,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
 	"strings"
@@ -25,6 +24,10 @@ type RApplication struct {
 	metadata struct {
 		label string
 		file  string
+		// synced is set for the restrictions read by
+		// SyncApplications, along with the contents of the plist.
+		synced   bool
+		contents string
 	}
 }
 
@@ -35,10 +38,13 @@ func NewApplication(item string) RApplication {
 	digest := sha512.Sum512([]byte(item))
 	// 2^24 items needed for a collision, fair enough.
 	app.metadata.label = fmt.Sprintf("%spkill%s", DndApplicationPrefix, hex.EncodeToString(digest[:6]))
-	app.metadata.file = filepath.Join(home, "Library", "LaunchAgents", app.metadata.label+".plist")
+	app.metadata.file = filepath.Join(applicationsDir(), app.metadata.label+".plist")
 	return app
 }
 
+// applicationsDir is the directory of the LaunchAgents of the user.
+func applicationsDir() string { return filepath.Join(homeDir(), "Library", "LaunchAgents") }
+
 func SyncApplications() ([]RApplication, error) {
 	type Plist struct {
 		Dict struct {
@@ -47,9 +53,12 @@ func SyncApplications() ([]RApplication, error) {
 		}
 	}
 
-	parentDir := filepath.Join(home, "Library", "LaunchAgents")
+	parentDir := applicationsDir()
 	entries, err := os.ReadDir(parentDir)
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -106,6 +115,8 @@ dir:
 			app := NewApplication(r.Dict.Pattern)
 			app.metadata.file = target
 			app.metadata.label = r.Dict.Label
+			app.metadata.synced = true
+			app.metadata.contents = string(b)
 			restrictions = append(restrictions, app)
 		}
 	}
@@ -117,17 +128,52 @@ dir:
 	return restrictions, errSynchronized
 }
 
-func (d *Diff) applicationCommit() error {
-	// if called with sudo, sudo_uid is the
-	// id of the caller who called sudo.
-	u := os.Getenv("SUDO_UID")
-	if u == "" {
-		user, _ := user.Current()
-		u = user.Uid
+// Key identifies the application restriction regardless of its contents.
+func (a RApplication) Key() string { return a.Pattern }
+
+// Equal compares the plists, so that a LaunchAgent edited outside
+// of dnd, i.e. its ProgramArguments, is detected.
+func (a RApplication) Equal(other Restriction) bool {
+	o, ok := other.(RApplication)
+	if !ok || a.Pattern != o.Pattern || a.metadata.label != o.metadata.label {
+		return false
 	}
+	return a.plist() == o.plist()
+}
+
+// plist returns the contents of the LaunchAgent as read by
+// SyncApplications, or as written by Apply if not synced.
+func (a RApplication) plist() string {
+	if a.metadata.synced {
+		return a.metadata.contents
+	}
+	return a.render()
+}
+
+func (a RApplication) Describe() string { return fmt.Sprintf("Pattern:%v\n", a.Pattern) }
+
+func (a RApplication) Items() []string { return []string{a.Pattern} }
 
-	// runes the pkill command on the given pattern every 30 secs.
-	contentsTemplate := `
+// launchctlDomain returns the gui domain of the user.
+func launchctlDomain() string {
+	// if elevated with sudo or pkexec, the domain is
+	// the one of the user that elevated the program.
+	u := invoker()
+	if u == nil {
+		u, _ = user.Current()
+	}
+	return fmt.Sprintf("gui/%s", u.Uid)
+}
+
+// xmlEscape escapes s for the character data of the plist.
+func xmlEscape(s string) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// runs the pkill command on the given pattern every 30 secs, see pkillCommand.
+const plistTemplate = `
 <?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
@@ -138,12 +184,7 @@ func (d *Diff) applicationCommit() error {
     <string>%s</string>
     <key>ProgramArguments</key>
     <array>
-		    <string>pkill</string>
-		    <string>-SIGKILL</string>
-		    <string>-i</string>
-		    <string>-f</string>
-		    <string>%s</string>
-    </array>
+%s    </array>
     <key>StandardOutPath</key>
     <string>%s</string>
     <key>StandardErrorPath</key>
@@ -154,62 +195,50 @@ func (d *Diff) applicationCommit() error {
 </plist>
 `
 
-	var errCommited error
-	var commited int
-
-	for _, d := range d.Delete {
-		app := d.(RApplication)
-
-		err := os.Remove(app.metadata.file)
-		if err != nil {
-			if !errors.Is(err, os.ErrNotExist) {
-				errCommited = errors.Join(errCommited, fmt.Errorf("failed to delete synced file %s: %w", app.metadata.file, err))
-				continue
-			}
-		}
-
-		commited++
-
-		// now, on new logins the service will not run, but to make it exit immmediately
-		//we need to remove it from launchctl.
-		output := bytes.Buffer{}
-		cmd := exec.Command("launchctl", "bootout", fmt.Sprintf("gui/%s/%s", u, app.metadata.label))
-		cmd.Stdout = &output
-		cmd.Stderr = &output
-		if err := cmd.Run(); err != nil {
-			errCommited = errors.Join(errCommited, fmt.Errorf("pkill removed for %s, but failed to disable it, a retry may be worth: %w: %s", app.metadata.label, err, output.String()))
-			continue
-		}
+// render returns the contents of the LaunchAgent.
+func (a RApplication) render() string {
+	logs := filepath.Join(filepath.Dir(ConfigPath()), fmt.Sprintf("%spkill.log", DndApplicationPrefix))
+	args := strings.Builder{}
+	for _, arg := range pkillCommand(a.Pattern) {
+		args.WriteString(fmt.Sprintf("\t\t    <string>%s</string>\n", xmlEscape(arg)))
 	}
+	return fmt.Sprintf(plistTemplate, xmlEscape(a.Pattern), a.metadata.label, args.String(), logs, logs)
+}
 
-	for _, d := range d.Missing {
-		app := d.(RApplication)
-
-		logs := filepath.Join(filepath.Dir(ConfigPath()), fmt.Sprintf("%spkill.log", DndApplicationPrefix))
-		contents := fmt.Sprintf(contentsTemplate, app.Pattern, app.metadata.label, app.Pattern, logs, logs)
+// Apply writes the LaunchAgent and loads it.
+func (a RApplication) Apply() error {
+	contents := a.render()
 
-		if err := atomicfile.Write(app.metadata.file, []byte(contents), 0644); err != nil {
-			errCommited = errors.Join(errCommited, fmt.Errorf("failed to write file %s: %w", app.metadata.file, err))
-			continue
-		}
+	if err := mkdirAllOwned(filepath.Dir(a.metadata.file), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", a.metadata.file, err)
+	}
+	if err := atomicfile.Write(a.metadata.file, []byte(contents), 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", a.metadata.file, err)
+	}
+	if err := chownToInvokingUser(a.metadata.file); err != nil {
+		return fmt.Errorf("failed to give file %s to the invoking user: %w", a.metadata.file, err)
+	}
 
-		commited++
+	// now, on new logins the service will run, but to make it run immmediately
+	// we need to load it using launchctl.
+	if output, err := run("launchctl", "bootstrap", launchctlDomain(), a.metadata.file); err != nil {
+		return fmt.Errorf("pkill configured for %s, but failed to immediately launch it, a retry may be worth:%w: %s", a.metadata.file, err, output)
+	}
+	return nil
+}
 
-		// now, on new logins the service will run, but to make it run immmediately
-		// we need to load it using launchctl.
-		output := bytes.Buffer{}
-		cmd := exec.Command("launchctl", "bootstrap", fmt.Sprintf("gui/%s", u), app.metadata.file)
-		cmd.Stdout = &output
-		cmd.Stderr = &output
-		if err := cmd.Run(); err != nil {
-			errCommited = errors.Join(errCommited, fmt.Errorf("pkill configured for %s, but failed to immediately launch it, a retry may be worth:%w: %s", app.metadata.file, err, output.String()))
-			continue
+// Revert removes the LaunchAgent and unloads it.
+func (a RApplication) Revert() error {
+	if err := os.Remove(a.metadata.file); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to delete synced file %s: %w", a.metadata.file, err)
 		}
 	}
 
-	if errCommited != nil && commited > 0 {
-		errCommited = fmt.Errorf("%w:%w", ErrPartialCommit, errCommited)
+	// now, on new logins the service will not run, but to make it exit immmediately
+	//we need to remove it from launchctl.
+	if output, err := run("launchctl", "bootout", fmt.Sprintf("%s/%s", launchctlDomain(), a.metadata.label)); err != nil {
+		return fmt.Errorf("pkill removed for %s, but failed to disable it, a retry may be worth: %w: %s", a.metadata.label, err, output)
 	}
-
-	return errCommited
+	return nil
 }
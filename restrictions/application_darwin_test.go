@@ -0,0 +1,71 @@
+//go:build darwin
+
+package restrictions
+
+import (
+	"os"
+	"os/user"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplicationCommitLaunchctl(t *testing.T) {
+	_, rec := testRoot(t)
+	t.Setenv("SUDO_UID", "")
+	t.Setenv("PKEXEC_UID", "")
+
+	u, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewApplication("Slack <&>")
+	if err := app.Apply(); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{"launchctl bootstrap gui/" + u.Uid + " " + app.metadata.file}
+	if got := commandStrings(rec.Commands()); !slices.Equal(got, want) {
+		t.Errorf("Apply() ran %q, want %q", got, want)
+	}
+
+	plist, err := os.ReadFile(app.metadata.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(plist), "<string>Slack &lt;&amp;&gt;</string>") {
+		t.Errorf("plist %s does not contain the escaped pattern:\n%s", app.metadata.file, plist)
+	}
+
+	synced, err := SyncApplications()
+	if err != nil {
+		t.Fatalf("SyncApplications() error = %v", err)
+	}
+	if len(synced) != 1 || !synced[0].Equal(app) {
+		t.Errorf("SyncApplications() = %+v, want %+v", synced, app)
+	}
+
+	// edited outside of dnd, the command no longer kills the application.
+	contents, err := os.ReadFile(app.metadata.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(contents), "<string>pkill</string>", "<string>true</string>", 1)
+	if err := os.WriteFile(app.metadata.file, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if synced, err = SyncApplications(); err != nil || len(synced) != 1 || synced[0].Equal(app) {
+		t.Errorf("SyncApplications() of the edited file = %+v, %v, want it to differ from %+v", synced, err, app)
+	}
+
+	if err := app.Revert(); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+	want = []string{"launchctl bootout gui/" + u.Uid + "/" + app.metadata.label}
+	if got := commandStrings(rec.Commands()); !slices.Equal(got, want) {
+		t.Errorf("Revert() ran %q, want %q", got, want)
+	}
+	if _, err := os.Stat(app.metadata.file); !os.IsNotExist(err) {
+		t.Errorf("%s not removed: %v", app.metadata.file, err)
+	}
+}
@@ -0,0 +1,270 @@
+//go:build linux
+
+package restrictions
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Despire/dnd/atomicfile"
+)
+
+type RApplication struct {
+	Pattern string
+
+	metadata struct {
+		label string
+		file  string
+		timer string
+		// synced is set for the restrictions read by SyncApplications,
+		// along with the contents of the service and the timer.
+		synced       bool
+		service      string
+		timerContent string
+	}
+}
+
+func NewApplication(item string) RApplication {
+	app := RApplication{
+		Pattern: item,
+	}
+	digest := sha512.Sum512([]byte(item))
+	// 2^24 items needed for a collision, fair enough.
+	app.metadata.label = fmt.Sprintf("%spkill%s", DndApplicationPrefix, hex.EncodeToString(digest[:6]))
+	app.metadata.file = filepath.Join(applicationsDir(), app.metadata.label+".service")
+	app.metadata.timer = filepath.Join(applicationsDir(), app.metadata.label+".timer")
+	return app
+}
+
+// applicationsDir is the directory of the systemd user units.
+func applicationsDir() string { return filepath.Join(homeDir(), ".config", "systemd", "user") }
+
+func SyncApplications() ([]RApplication, error) {
+	parentDir := applicationsDir()
+	entries, err := os.ReadDir(parentDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var restrictions []RApplication
+	var errSynchronized error
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".service" {
+			continue
+		}
+
+		label := strings.TrimSuffix(e.Name(), ".service")
+		if !strings.HasPrefix(label, DndApplicationPrefix) {
+			continue
+		}
+
+		target := filepath.Join(parentDir, e.Name())
+		b, err := os.ReadFile(target)
+		if err != nil {
+			errSynchronized = errors.Join(errSynchronized, fmt.Errorf("failed to read file %s: %w", target, err))
+			continue
+		}
+
+		pattern := ""
+		s := bufio.NewScanner(bytes.NewReader(b))
+		for s.Scan() {
+			key, value, ok := strings.Cut(s.Text(), "=")
+			if ok && strings.TrimSpace(key) == "X-TargetedPattern" {
+				pattern = unescapeUnitValue(value)
+			}
+		}
+		if err := s.Err(); err != nil {
+			errSynchronized = errors.Join(errSynchronized, fmt.Errorf("failed decoding file %s: %w", target, err))
+			continue
+		}
+
+		if pattern != "" {
+			app := NewApplication(pattern)
+			app.metadata.file = target
+			app.metadata.timer = strings.TrimSuffix(target, ".service") + ".timer"
+			app.metadata.label = label
+			app.metadata.synced = true
+			app.metadata.service = string(b)
+			// a missing timer differs from any rendered one.
+			if timer, err := os.ReadFile(app.metadata.timer); err == nil {
+				app.metadata.timerContent = string(timer)
+			}
+			restrictions = append(restrictions, app)
+		}
+	}
+
+	if errSynchronized != nil && len(restrictions) > 0 {
+		errSynchronized = fmt.Errorf("%w: %w", ErrPartialSync, errSynchronized)
+	}
+
+	return restrictions, errSynchronized
+}
+
+// systemctl runs systemctl against the user manager of the caller.
+// If elevated with sudo or pkexec the manager of the user that elevated
+// the program is targeted instead of the one of root.
+func systemctl(args ...string) error {
+	cmdArgs := []string{"--user"}
+	if u := invoker(); u != nil {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--machine=%s@.host", u.Username))
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	if output, err := run("systemctl", cmdArgs...); err != nil {
+		return fmt.Errorf("%w: %s", err, output)
+	}
+	return nil
+}
+
+// escapeUnitValue escapes s for the value of a unit file setting, so
+// that it is neither expanded as a specifier nor spans multiple lines.
+func escapeUnitValue(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "%", "%%", "\n", `\n`, "\r", `\r`)
+	return r.Replace(s)
+}
+
+// unescapeUnitValue reverts escapeUnitValue.
+func unescapeUnitValue(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, "%%", "%", `\n`, "\n", `\r`, "\r")
+	return r.Replace(s)
+}
+
+// quoteExecArg quotes s so that systemd passes it
+// unmodified as a single argument to the executed command.
+func quoteExecArg(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(s) + `"`
+}
+
+// Key identifies the application restriction regardless of its contents.
+func (a RApplication) Key() string { return a.Pattern }
+
+// Equal compares the unit files, so that a service or a timer
+// edited outside of dnd, i.e. its ExecStart, is detected.
+func (a RApplication) Equal(other Restriction) bool {
+	o, ok := other.(RApplication)
+	if !ok || a.Pattern != o.Pattern || a.metadata.label != o.metadata.label {
+		return false
+	}
+	service, timer := a.units()
+	otherService, otherTimer := o.units()
+	return service == otherService && timer == otherTimer
+}
+
+// units returns the contents of the service and the timer as read
+// by SyncApplications, or as written by Apply if not synced.
+func (a RApplication) units() (service, timer string) {
+	if a.metadata.synced {
+		return a.metadata.service, a.metadata.timerContent
+	}
+	return a.render()
+}
+
+func (a RApplication) Describe() string { return fmt.Sprintf("Pattern:%v\n", a.Pattern) }
+
+func (a RApplication) Items() []string { return []string{a.Pattern} }
+
+// runs the pkill command on the given pattern every 30 secs.
+// The '-' prefix ignores the exit status of pkill when no
+// process matched the pattern, see pkillCommand.
+const serviceTemplate = `[Unit]
+Description=dnd restriction for %s
+X-TargetedPattern=%s
+
+[Service]
+Type=oneshot
+ExecStart=-%s
+StandardOutput=append:%s
+StandardError=append:%s
+`
+
+const timerTemplate = `[Unit]
+Description=dnd restriction timer for %s
+
+[Timer]
+OnActiveSec=0
+OnUnitActiveSec=30s
+AccuracySec=1s
+
+[Install]
+WantedBy=timers.target
+`
+
+// render returns the contents of the systemd service and timer.
+func (a RApplication) render() (service, timer string) {
+	logs := filepath.Join(filepath.Dir(ConfigPath()), fmt.Sprintf("%spkill.log", DndApplicationPrefix))
+	pkill := pkillCommand(a.Pattern)
+	command := []string{pkill[0]}
+	for _, arg := range pkill[1:] {
+		command = append(command, quoteExecArg(arg))
+	}
+	pattern := escapeUnitValue(a.Pattern)
+	service = fmt.Sprintf(serviceTemplate, pattern, pattern, strings.Join(command, " "), logs, logs)
+	timer = fmt.Sprintf(timerTemplate, pattern)
+	return service, timer
+}
+
+// Apply writes the systemd service and timer and starts the timer.
+func (a RApplication) Apply() error {
+	if err := mkdirAllOwned(filepath.Dir(a.metadata.file), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", a.metadata.file, err)
+	}
+
+	service, timer := a.render()
+
+	if err := atomicfile.Write(a.metadata.file, []byte(service), 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", a.metadata.file, err)
+	}
+	if err := atomicfile.Write(a.metadata.timer, []byte(timer), 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", a.metadata.timer, err)
+	}
+	if err := chownToInvokingUser(a.metadata.file, a.metadata.timer); err != nil {
+		return fmt.Errorf("failed to give the files of %s to the invoking user: %w", a.metadata.label, err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return fmt.Errorf("failed to reload systemd user units, a retry may be worth: %w", err)
+	}
+
+	// now, on new logins the timer will run, but to make it run immediately
+	// we need to start it using systemctl.
+	if err := systemctl("enable", "--now", a.metadata.label+".timer"); err != nil {
+		return fmt.Errorf("pkill configured for %s, but failed to immediately launch it, a retry may be worth: %w", a.metadata.file, err)
+	}
+	return nil
+}
+
+// Revert stops the timer and removes the systemd service and timer.
+func (a RApplication) Revert() error {
+	// the timer needs to be disabled before the unit files are
+	// removed, otherwise systemd can't clean up the symlinks.
+	errRevert := systemctl("disable", "--now", a.metadata.label+".timer")
+	if errRevert != nil {
+		errRevert = fmt.Errorf("failed to disable pkill for %s, a retry may be worth: %w", a.metadata.label, errRevert)
+	}
+
+	for _, f := range []string{a.metadata.timer, a.metadata.file} {
+		if err := os.Remove(f); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errRevert = errors.Join(errRevert, fmt.Errorf("failed to delete synced file %s: %w", f, err))
+		}
+	}
+	if errRevert != nil {
+		return errRevert
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return fmt.Errorf("failed to reload systemd user units, a retry may be worth: %w", err)
+	}
+	return nil
+}
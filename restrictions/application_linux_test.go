@@ -0,0 +1,81 @@
+//go:build linux
+
+package restrictions
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplicationCommitSystemctl(t *testing.T) {
+	_, rec := testRoot(t)
+	t.Setenv("SUDO_UID", "")
+	t.Setenv("PKEXEC_UID", "")
+
+	app := NewApplication(`Slack 100% \ "x"`)
+	if err := app.Apply(); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := []string{
+		"systemctl --user daemon-reload",
+		"systemctl --user enable --now " + app.metadata.label + ".timer",
+	}
+	if got := commandStrings(rec.Commands()); !slices.Equal(got, want) {
+		t.Errorf("Apply() ran %q, want %q", got, want)
+	}
+
+	service, err := os.ReadFile(app.metadata.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`X-TargetedPattern=Slack 100%% \\ "x"`,
+		`ExecStart=-pkill "-SIGKILL" "-i" "-f" "Slack 100%% \\ \"x\""`,
+	} {
+		if !strings.Contains(string(service), line+"\n") {
+			t.Errorf("service %s does not contain %q:\n%s", app.metadata.file, line, service)
+		}
+	}
+	if _, err := os.Stat(app.metadata.timer); err != nil {
+		t.Errorf("timer not written: %v", err)
+	}
+
+	synced, err := SyncApplications()
+	if err != nil {
+		t.Fatalf("SyncApplications() error = %v", err)
+	}
+	if len(synced) != 1 || !synced[0].Equal(app) {
+		t.Errorf("SyncApplications() = %+v, want %+v", synced, app)
+	}
+
+	// edited outside of dnd, the command no longer kills the application.
+	contents, err := os.ReadFile(app.metadata.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(contents), "ExecStart=-pkill", "ExecStart=-true", 1)
+	if err := os.WriteFile(app.metadata.file, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if synced, err = SyncApplications(); err != nil || len(synced) != 1 || synced[0].Equal(app) {
+		t.Errorf("SyncApplications() of the edited file = %+v, %v, want it to differ from %+v", synced, err, app)
+	}
+
+	if err := app.Revert(); err != nil {
+		t.Fatalf("Revert() error = %v", err)
+	}
+	want = []string{
+		"systemctl --user disable --now " + app.metadata.label + ".timer",
+		"systemctl --user daemon-reload",
+	}
+	if got := commandStrings(rec.Commands()); !slices.Equal(got, want) {
+		t.Errorf("Revert() ran %q, want %q", got, want)
+	}
+	for _, f := range []string{app.metadata.file, app.metadata.timer} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", f, err)
+		}
+	}
+}
@@ -1,4 +1,4 @@
-//go:build !darwin
+//go:build !darwin && !linux
 
 package restrictions
 
@@ -14,10 +14,27 @@ func NewApplication(item string) RApplication {
 	}
 }
 
+func applicationsDir() string { return "" }
+
 func SyncApplications() ([]RApplication, error) {
 	return nil, errors.New("not implemented")
 }
 
-func (d *Diff) applicationCommit() error {
+func (a RApplication) Key() string { return a.Pattern }
+
+func (a RApplication) Equal(other Restriction) bool {
+	o, ok := other.(RApplication)
+	return ok && a == o
+}
+
+func (a RApplication) Describe() string { return "Pattern:" + a.Pattern + "\n" }
+
+func (a RApplication) Items() []string { return []string{a.Pattern} }
+
+func (a RApplication) Apply() error {
+	return errors.New("not implemented")
+}
+
+func (a RApplication) Revert() error {
 	return errors.New("not implemented")
 }
@@ -0,0 +1,78 @@
+package restrictions
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Despire/dnd/atomicfile"
+)
+
+// maxApplying bounds how long a marker is honored, so that a marker
+// left behind, or naming a process that never exits, doesn't disable
+// the tamper detection for good.
+const maxApplying = 5 * time.Minute
+
+// applyingMarker names the process changing the restrictions, see MarkApplying.
+type applyingMarker struct {
+	PID  int
+	Time time.Time
+}
+
+func applyingPath() string { return filepath.Join(StateDir(), "applying") }
+
+// markApplying writes the marker for the process under the StateDir,
+// where the user can't modify it.
+func markApplying(pid int, now time.Time) error {
+	if err := os.MkdirAll(StateDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", StateDir(), err)
+	}
+	b, err := json.Marshal(applyingMarker{PID: pid, Time: now})
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(applyingPath(), b, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", applyingPath(), err)
+	}
+	return nil
+}
+
+// MarkApplying records that the process is changing the restrictions
+// at the OS level, until the returned function is called or the process
+// exits. Meanwhile the changes are not treated as tampering. Without
+// root the helper marks the process along with the first change it
+// makes, see requestCommit, until the process exits.
+func MarkApplying() (func(), error) {
+	if !privileged() {
+		return func() {}, nil
+	}
+	if err := markApplying(os.Getpid(), time.Now()); err != nil {
+		return func() {}, err
+	}
+	return func() { os.Remove(applyingPath()) }, nil
+}
+
+// Applying reports whether another process that is still running
+// is changing the restrictions, for at most maxApplying. A marker
+// that can't be read is ignored.
+func Applying() bool {
+	b, err := os.ReadFile(applyingPath())
+	if err != nil {
+		return false
+	}
+	var m applyingMarker
+	if err := json.Unmarshal(b, &m); err != nil {
+		return false
+	}
+	if m.PID == os.Getpid() || time.Since(m.Time) > maxApplying {
+		return false
+	}
+	procs, err := Processes()
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(procs, func(p Process) bool { return p.PID == m.PID })
+}
@@ -0,0 +1,59 @@
+package restrictions
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestApplying(t *testing.T) {
+	testRoot(t)
+
+	done, err := MarkApplying()
+	if err != nil {
+		t.Fatalf("MarkApplying() error = %v", err)
+	}
+	if _, err := os.Stat(applyingPath()); err != nil {
+		t.Fatalf("marker not written under the state dir: %v", err)
+	}
+	if Applying() {
+		t.Error("Applying() of the marking process itself")
+	}
+	done()
+	if _, err := os.Stat(applyingPath()); !os.IsNotExist(err) {
+		t.Errorf("marker not removed: %v", err)
+	}
+
+	if runtime.GOOS != "linux" {
+		t.Skip("the processes are listed with ps, which is only recorded under a root")
+	}
+
+	// the parent of the test is running, while no process has a pid this large.
+	other, gone := os.Getppid(), 1<<30
+	tests := []struct {
+		name   string
+		pid    int
+		marked time.Time
+		want   bool
+	}{
+		{name: "running", pid: other, marked: time.Now(), want: true},
+		{name: "exited", pid: gone, marked: time.Now()},
+		{name: "expired", pid: other, marked: time.Now().Add(-maxApplying - time.Minute)},
+	}
+	for _, tt := range tests {
+		if err := markApplying(tt.pid, tt.marked); err != nil {
+			t.Fatal(err)
+		}
+		if got := Applying(); got != tt.want {
+			t.Errorf("%s: Applying() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if err := os.WriteFile(applyingPath(), []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if Applying() {
+		t.Error("Applying() with an unreadable marker")
+	}
+}
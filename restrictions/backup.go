@@ -0,0 +1,264 @@
+package restrictions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Despire/dnd/atomicfile"
+)
+
+// BackupRetention is the number of backups kept, older ones are deleted.
+const BackupRetention = 20
+
+// backupIDFormat is the layout of the time a backup is identified by.
+const backupIDFormat = "20060102T150405.000Z"
+
+// ErrBackupNotFound is returned when restoring a backup that doesn't exist.
+var ErrBackupNotFound = errors.New("backup not found")
+
+// Backup is a copy of the files where the restrictions are enforced.
+type Backup struct {
+	ID   string
+	Time time.Time
+	// Files are the paths of the copied files relative to the backup.
+	Files []string
+}
+
+func BackupsDir() string { return filepath.Join(StateDir(), "backups") }
+
+// CreateBackup copies the files of all kinds to a new backup. Without
+// root the privileged helper is asked to do it, which also prunes the
+// old backups, see RunHelper.
+func CreateBackup(now time.Time) (Backup, error) {
+	if !privileged() {
+		var b Backup
+		out, err := callHelper(HelperRequest{Op: helperBackup})
+		if err != nil {
+			return b, err
+		}
+		if err := json.Unmarshal(out, &b); err != nil {
+			return b, fmt.Errorf("failed to decode the backup created by the helper: %w", err)
+		}
+		return b, nil
+	}
+
+	b := Backup{
+		ID:   now.UTC().Format(backupIDFormat),
+		Time: now.UTC(),
+	}
+	dir := filepath.Join(BackupsDir(), b.ID)
+	if _, err := os.Stat(dir); err == nil {
+		return b, fmt.Errorf("backup %s already exists", b.ID)
+	}
+
+	// the backup is created under a temporary name so that
+	// an interrupted backup is never listed.
+	tmp := dir + ".tmp"
+	if err := os.MkdirAll(tmp, 0755); err != nil {
+		return b, fmt.Errorf("failed to create directory %s: %w", tmp, err)
+	}
+	for _, k := range Kinds() {
+		if k.Backup == nil {
+			continue
+		}
+		if err := k.Backup(tmp); err != nil {
+			os.RemoveAll(tmp)
+			return b, fmt.Errorf("failed to back up %s restrictions: %w", k.Type, err)
+		}
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		os.RemoveAll(tmp)
+		return b, fmt.Errorf("failed to store backup %s: %w", b.ID, err)
+	}
+	return b, nil
+}
+
+// Backups returns the stored backups, newest first.
+func Backups() ([]Backup, error) {
+	entries, err := os.ReadDir(BackupsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, e := range entries {
+		t, err := time.Parse(backupIDFormat, e.Name())
+		if !e.IsDir() || err != nil {
+			continue
+		}
+		b := Backup{ID: e.Name(), Time: t}
+		root := filepath.Join(BackupsDir(), e.Name())
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(root, path)
+				b.Files = append(b.Files, rel)
+			}
+			return nil
+		})
+		backups = append(backups, b)
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return b.Time.Compare(a.Time) })
+	return backups, nil
+}
+
+// RestoreBackup brings the files of all kinds back to the
+// state stored in the backup. Requires root.
+func RestoreBackup(id string) error {
+	if _, err := time.Parse(backupIDFormat, id); err != nil {
+		return fmt.Errorf("%w: %s", ErrBackupNotFound, id)
+	}
+	dir := filepath.Join(BackupsDir(), id)
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("%w: %s", ErrBackupNotFound, id)
+	}
+
+	var errRestore error
+	for _, k := range Kinds() {
+		if k.Restore == nil {
+			continue
+		}
+		if err := k.Restore(dir); err != nil {
+			errRestore = errors.Join(errRestore, fmt.Errorf("failed to restore %s restrictions: %w", k.Type, err))
+		}
+	}
+	return errRestore
+}
+
+// PruneBackups deletes the oldest backups exceeding the BackupRetention.
+// Without root, the backups were pruned when the last one was created.
+func PruneBackups() error {
+	if !privileged() {
+		return nil
+	}
+	backups, err := Backups()
+	if err != nil {
+		return err
+	}
+	var errPrune error
+	for i := BackupRetention; i < len(backups); i++ {
+		if err := os.RemoveAll(filepath.Join(BackupsDir(), backups[i].ID)); err != nil {
+			errPrune = errors.Join(errPrune, err)
+		}
+	}
+	return errPrune
+}
+
+// backupPruned creates a backup, see CreateBackup, and prunes the old ones.
+func backupPruned(now time.Time) (Backup, error) {
+	b, err := CreateBackup(now)
+	if err != nil {
+		return b, err
+	}
+	// failing to prune is not fatal, the next backup prunes again.
+	PruneBackups()
+	return b, nil
+}
+
+// copyFile copies the file, a missing source is not an error.
+func copyFile(dst, src string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return atomicfile.Write(dst, b, 0644)
+}
+
+// backupHosts copies the hosts file to the backup.
+func backupHosts(dir string) error { return copyFile(filepath.Join(dir, "hosts"), hostsPath()) }
+
+// restoreHosts replaces the hosts file with the one in the backup.
+func restoreHosts(dir string) error {
+	b, err := os.ReadFile(filepath.Join(dir, "hosts"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return writeHosts(b)
+}
+
+// backupApplications copies the files of the application
+// restrictions managed by dnd to the backup.
+func backupApplications(dir string) error {
+	if applicationsDir() == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(applicationsDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), DndApplicationPrefix) {
+			continue
+		}
+		if err := copyFile(filepath.Join(dir, "applications", e.Name()), filepath.Join(applicationsDir(), e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreApplications reverts the current application restrictions
+// and applies the ones stored in the backup.
+func restoreApplications(dir string) error {
+	if applicationsDir() == "" {
+		return nil
+	}
+
+	current, err := SyncApplications()
+	if err != nil && !errors.Is(err, ErrPartialSync) {
+		return err
+	}
+	var errRestore error
+	for _, a := range current {
+		if err := a.Revert(); err != nil {
+			errRestore = errors.Join(errRestore, err)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "applications"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Join(errRestore, err)
+	}
+	for _, e := range entries {
+		dst := filepath.Join(applicationsDir(), e.Name())
+		if err := copyFile(dst, filepath.Join(dir, "applications", e.Name())); err != nil {
+			errRestore = errors.Join(errRestore, err)
+			continue
+		}
+		if err := chownToInvokingUser(dst); err != nil {
+			errRestore = errors.Join(errRestore, err)
+		}
+	}
+
+	// applying the restored restrictions loads them again.
+	restored, err := SyncApplications()
+	if err != nil && !errors.Is(err, ErrPartialSync) {
+		return errors.Join(errRestore, err)
+	}
+	for _, a := range restored {
+		if err := a.Apply(); err != nil {
+			errRestore = errors.Join(errRestore, err)
+		}
+	}
+	return errRestore
+}
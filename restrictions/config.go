@@ -15,8 +15,27 @@ type Config struct {
 	LastCommited *Config `json:"LastCommited,omitempty"`
 	// Version of the config
 	Version int64
-	// Currently stored restrictions.
-	Restrictions map[Type]List
+	// Current is the name of the profile in use. Its restrictions
+	// are stored in the embedded Profile.
+	Current string `json:",omitempty"`
+	// Profile in use.
+	Profile
+	// Profiles that are not in use, keyed by their name.
+	Profiles map[string]*Profile `json:",omitempty"`
+	// Lock prevents removing restrictions until it expires.
+	Lock *Lock `json:",omitempty"`
+	// Allowances temporarily lifting restrictions of the profile in use.
+	Allowances []Allowance `json:",omitempty"`
+	// AllowanceBudget is the number of allowances that can be granted
+	// per day, DefaultAllowanceBudget if not set.
+	AllowanceBudget int `json:",omitempty"`
+	// Sinkhole addresses for the blocked domains, DefaultSinkhole if not set.
+	Sinkhole *Sinkhole `json:",omitempty"`
+	// Subscriptions to blocklists, keyed by their name.
+	Subscriptions map[string]*Subscription `json:",omitempty"`
+	// Results are the outcomes of the items of the last
+	// commit, only set for the LastCommited config.
+	Results map[Type]map[string]Result `json:",omitempty"`
 }
 
 func ReadConfig() (*Config, error) {
@@ -34,28 +53,47 @@ func ReadConfig() (*Config, error) {
 	return &cfg, err
 }
 
+// WriteConfig writes the config with its version bumped. Returns
+// ErrConflict if the config was written since c was read.
 func WriteConfig(c *Config) error {
-	c = &Config{
-		LastCommited: c.LastCommited,
-		Version:      c.Version + 1,
-		Restrictions: c.Restrictions,
+	current, err := ReadConfig()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		current = &Config{}
+	case err != nil:
+		return fmt.Errorf("failed to read config: %w", err)
 	}
-	b, err := json.Marshal(c)
+	if current.Version != c.Version {
+		return fmt.Errorf("%w, the config is at version %v but version %v was changed, retry", ErrConflict, current.Version, c.Version)
+	}
+
+	next := *c
+	next.Version++
+	b, err := json.Marshal(&next)
 	if err != nil {
 		return err
 	}
 	if err := atomicfile.Write(ConfigPath(), b, os.ModePerm); err != nil {
 		return fmt.Errorf("failed to atomically write config: %w", err)
 	}
+	if err := chownToInvokingUser(ConfigPath()); err != nil {
+		return fmt.Errorf("config written, but failed to give it to the invoking user: %w", err)
+	}
+	if err := appendHistory(next); err != nil {
+		return fmt.Errorf("config written, but failed to record it in the history %s: %w", HistoryPath(), err)
+	}
 	return nil
 }
 
-func ConfigPath() string { return fmt.Sprintf("%s/.dnd_config/.config", home) }
+func ConfigPath() string { return filepath.Join(homeDir(), ".dnd_config", ".config") }
 
 func CreateConfigDir() error {
+	if homeDir() == "" {
+		return errors.New("failed to determine the home directory, is $HOME set ?")
+	}
 	dir := filepath.Dir(ConfigPath())
 	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
-		return os.MkdirAll(filepath.Dir(ConfigPath()), os.ModePerm)
+		return mkdirAllOwned(filepath.Dir(ConfigPath()), os.ModePerm)
 	}
 	return nil
 }
@@ -0,0 +1,59 @@
+package restrictions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrConfigBusy is returned when the config stays locked by another invocation.
+	ErrConfigBusy = errors.New("config is in use by another dnd invocation")
+
+	// ErrConflict is returned when writing a config that was
+	// changed by someone else since it was read.
+	ErrConflict = errors.New("config was changed by another dnd invocation")
+)
+
+// configLockTimeout is how long LockConfig waits for the lock.
+const configLockTimeout = 10 * time.Second
+
+func configLockPath() string { return filepath.Join(filepath.Dir(ConfigPath()), ".lock") }
+
+// LockConfig takes an advisory lock of the config, held until the
+// returned function is called. Meant to guard a ReadConfig, modify
+// and WriteConfig sequence against concurrent invocations.
+func LockConfig() (func(), error) {
+	f, err := os.OpenFile(configLockPath(), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file %s: %w", configLockPath(), err)
+	}
+	if err := chownToInvokingUser(configLockPath()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to give lock file %s to the invoking user: %w", configLockPath(), err)
+	}
+
+	deadline := time.Now().Add(configLockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", configLockPath(), err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, fmt.Errorf("%w, gave up after %v", ErrConfigBusy, configLockTimeout)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
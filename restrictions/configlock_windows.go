@@ -0,0 +1,10 @@
+//go:build windows
+
+package restrictions
+
+import "os"
+
+// tryLockFile is not implemented, the config is not locked.
+func tryLockFile(f *os.File) (bool, error) { return true, nil }
+
+func unlockFile(f *os.File) error { return nil }
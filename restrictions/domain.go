@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"strings"
+	"time"
+
+	"golang.org/x/net/idna"
 )
 
 // RDomain is a domain restrictions
@@ -15,6 +20,8 @@ import (
 type RDomain struct {
 	header string
 	footer string
+	// group is the name of the group of domains, if any.
+	group string
 
 	Restrictions []struct {
 		IP      string
@@ -23,24 +30,204 @@ type RDomain struct {
 	}
 }
 
-func NewDomain(item string) RDomain {
+func init() {
+	Register(Kind{
+		Type: Domain,
+		Name: "Domain",
+		Help: "A single domain name or a list of domains separated with ',' [www.google.com,www.youtube.com]\n" +
+			"\ta domain prefixed with '*.' also blocks its common subdomains [*.youtube.com]\n" +
+			"\tURLs, hosts file and adblock style (||example.com^) entries are accepted",
+		Parse: func(entry string) ([]string, error) {
+			var items []string
+			var errParse error
+			for _, d := range ParseDomainEntry(entry) {
+				normalized, err := NormalizeDomain(d)
+				if err != nil {
+					errParse = errors.Join(errParse, err)
+					continue
+				}
+				items = append(items, normalized)
+			}
+			return items, errParse
+		},
+		Sync:    func() ([]Restriction, error) { r, err := SyncDomains(); return toRestrictions(r), err },
+		New:     func(c *Config, item string) Restriction { return NewDomain(item, c.Sinkhole.OrDefault()) },
+		Watch:   func() []string { return []string{hostsPath()} },
+		Backup:  backupHosts,
+		Restore: restoreHosts,
+		Commit:  commitDomains,
+		// the hosts file is owned by root.
+		Privileged: true,
+		Extra: func(c *Config) ([]Restriction, error) {
+			// a sinkhole set before it was validated is never commited.
+			if err := ValidateSinkhole(c.Sinkhole.OrDefault()); err != nil {
+				return nil, fmt.Errorf("refusing the configured sinkhole: %w", err)
+			}
+			groups, err := c.SubscriptionGroups()
+			if err != nil && !errors.Is(err, ErrPartialSync) {
+				return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+			}
+			return toRestrictions(groups), err // can be partial error
+		},
+	})
+}
+
+// NormalizeDomain validates the domain and converts it into the form
+// used in the hosts file. The domain is lowercased, the trailing dot
+// is stripped and internationalized domain names are converted to
+// punycode, i.e. bücher.de becomes xn--bcher-kva.de.
+func NormalizeDomain(item string) (string, error) {
+	domain := strings.TrimSpace(item)
+	domain = strings.TrimSuffix(domain, ".")
+
+	wildcard := false
+	if rest, ok := strings.CutPrefix(domain, "*."); ok {
+		wildcard = true
+		domain = rest
+	}
+
+	if domain == "" {
+		return "", fmt.Errorf("invalid domain %q: empty hostname", item)
+	}
+	if net.ParseIP(domain) != nil {
+		return "", fmt.Errorf("invalid domain %q: IP addresses can't be blocked through the hosts file", item)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", item, err)
+	}
+	ascii = strings.ToLower(ascii)
+
+	for _, label := range strings.Split(ascii, ".") {
+		if label == "" || len(label) > 63 {
+			return "", fmt.Errorf("invalid domain %q: labels must be between 1 and 63 characters", item)
+		}
+	}
+
+	if ascii == "localhost" || strings.HasSuffix(ascii, ".localhost") {
+		return "", fmt.Errorf("invalid domain %q: refusing to block localhost", item)
+	}
+
+	if wildcard {
+		// *.com would block the top level domain itself.
+		if !strings.Contains(ascii, ".") {
+			return "", fmt.Errorf("invalid domain %q: a wildcard needs a domain, not a top level domain", item)
+		}
+		ascii = "*." + ascii
+	}
+	return ascii, nil
+}
+
+// WildcardSubdomains are the subdomains a wildcard domain, such as
+// *.youtube.com, is expanded to. The hosts file can't express
+// wildcards, thus only the commonly used subdomains are blocked.
+var WildcardSubdomains = []string{
+	"www", "m", "mobile", "touch", "amp",
+	"app", "apps", "api", "web",
+	"login", "accounts", "auth",
+	"static", "cdn", "img", "images", "media",
+	"video", "videos", "music", "tv", "play",
+	"news", "mail", "help", "support", "community",
+}
+
+// ExpandDomain returns the domains that are blocked for
+// the given item. A wildcard item *.<domain> is expanded
+// to the domain itself and its WildcardSubdomains.
+func ExpandDomain(item string) []string {
+	base, ok := strings.CutPrefix(item, "*.")
+	if !ok {
+		return []string{item}
+	}
+	domains := []string{base}
+	for _, sub := range WildcardSubdomains {
+		domains = append(domains, sub+"."+base)
+	}
+	return domains
+}
+
+// Sinkhole are the addresses the blocked domains resolve to.
+type Sinkhole struct {
+	IPv4 string `json:",omitempty"`
+	IPv6 string `json:",omitempty"`
+}
+
+// DefaultSinkhole is used for any address family without
+// a configured sinkhole address.
+var DefaultSinkhole = Sinkhole{IPv4: "127.0.0.1", IPv6: "::1"}
+
+// OrDefault returns the sinkhole with the addresses that are not
+// set, or all of them for a nil sinkhole, taken from the DefaultSinkhole.
+func (s *Sinkhole) OrDefault() Sinkhole {
+	r := DefaultSinkhole
+	if s == nil {
+		return r
+	}
+	if s.IPv4 != "" {
+		r.IPv4 = s.IPv4
+	}
+	if s.IPv6 != "" {
+		r.IPv6 = s.IPv6
+	}
+	return r
+}
+
+// ValidateSinkhole checks that the addresses belong to the right family
+// and are sinkhole addresses, see sinkholeAddress.
+func ValidateSinkhole(s Sinkhole) error {
+	if s.IPv4 != "" {
+		if ip := net.ParseIP(s.IPv4); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid IPv4 sinkhole address %q", s.IPv4)
+		}
+		if err := sinkholeAddress(s.IPv4); err != nil {
+			return err
+		}
+	}
+	if s.IPv6 != "" {
+		if ip := net.ParseIP(s.IPv6); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid IPv6 sinkhole address %q", s.IPv6)
+		}
+		if err := sinkholeAddress(s.IPv6); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sinkholeAddress checks that the address is a loopback or an
+// unspecified address, so that the blocked domains can't be
+// redirected to a host on the network.
+func sinkholeAddress(addr string) error {
+	ip := net.ParseIP(addr)
+	if ip == nil || !(ip.IsLoopback() || ip.IsUnspecified()) {
+		return fmt.Errorf("address %q is neither a loopback nor an unspecified address", addr)
+	}
+	return nil
+}
+
+// NewDomain returns the restriction pointing the
+// domains of the item to the sinkhole addresses.
+func NewDomain(item string, sinkhole Sinkhole) RDomain {
 	digest := sha512.Sum512([]byte(item))
 	guard := fmt.Sprintf("%s%s\n", DndDomainPrefix, hex.EncodeToString(digest[:16]))
 
 	r := RDomain{
 		header: guard,
 		footer: guard,
-		Restrictions: []struct {
-			IP      string
-			Domains []string
-			Raw     string
-		}{
-			{
-				IP:      "127.0.0.1",
-				Domains: []string{item},
-				Raw:     fmt.Sprintf("127.0.0.1 %s\n", item),
-			},
-		},
+	}
+
+	for _, domain := range ExpandDomain(item) {
+		for _, ip := range []string{sinkhole.IPv4, sinkhole.IPv6} {
+			r.Restrictions = append(r.Restrictions, struct {
+				IP      string
+				Domains []string
+				Raw     string
+			}{
+				IP:      ip,
+				Domains: []string{domain},
+				Raw:     fmt.Sprintf("%s %s\n", ip, domain),
+			})
+		}
 	}
 
 	return r
@@ -58,7 +245,72 @@ func (d RDomain) String() string {
 	return builder.String()
 }
 
-func (d RDomain) Equal(o RDomain) bool {
+// Key identifies the domain restriction regardless of its contents.
+func (d RDomain) Key() string { return d.header }
+
+// maxDescribedEntries limits the number of described entries of
+// a single domain restriction, such as a subscription.
+const maxDescribedEntries = 64
+
+func (d RDomain) Describe() string {
+	b := strings.Builder{}
+	if d.group != "" {
+		b.WriteString(fmt.Sprintf("Group:%s\tEntries:%v\n", d.group, len(d.Restrictions)))
+	}
+	for i, r := range d.Restrictions {
+		if i == maxDescribedEntries {
+			b.WriteString(fmt.Sprintf("... and %v more\n", len(d.Restrictions)-i))
+			break
+		}
+		b.WriteString(fmt.Sprintf("IP:%s\tDomains:%v\n", r.IP, r.Domains))
+	}
+	return b.String()
+}
+
+// Items returns the blocked domains, without duplicates.
+func (d RDomain) Items() []string {
+	var items []string
+	seen := make(map[string]struct{})
+	for _, r := range d.Restrictions {
+		for _, domain := range r.Domains {
+			if _, ok := seen[domain]; ok {
+				continue
+			}
+			seen[domain] = struct{}{}
+			items = append(items, domain)
+		}
+	}
+	return items
+}
+
+// Covers reports whether every domain of the other restriction is
+// pointed to the same address by d.
+func (d RDomain) Covers(other Restriction) bool {
+	o, ok := other.(RDomain)
+	if !ok {
+		return false
+	}
+	entries := make(map[string]struct{})
+	for _, r := range d.Restrictions {
+		for _, domain := range r.Domains {
+			entries[r.IP+" "+domain] = struct{}{}
+		}
+	}
+	for _, r := range o.Restrictions {
+		for _, domain := range r.Domains {
+			if _, ok := entries[r.IP+" "+domain]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (d RDomain) Equal(other Restriction) bool {
+	o, ok := other.(RDomain)
+	if !ok {
+		return false
+	}
 	if len(d.Restrictions) != len(o.Restrictions) {
 		return false
 	}
@@ -78,9 +330,34 @@ func (d RDomain) Equal(o RDomain) bool {
 	return d.header == o.header && d.footer == o.footer
 }
 
+// commitDomains removes the deleted blocks from the hosts file and
+// appends the missing ones with a single write, so that either the
+// whole difference is commited or none of it. Without root the
+// privileged helper is asked to do it, see RunHelper.
+func commitDomains(d Diff) error {
+	_, err := requestCommit(d, false, nil, time.Now())
+	return err
+}
+
+// domainBlocks converts the restrictions of a domain difference.
+func domainBlocks(restrictions []Restriction) ([]RDomain, error) {
+	var blocks []RDomain
+	for _, r := range restrictions {
+		d, ok := r.(RDomain)
+		if !ok {
+			return nil, fmt.Errorf("unexpected %T restriction in the domain difference", r)
+		}
+		blocks = append(blocks, d)
+	}
+	return blocks, nil
+}
+
 func SyncDomains() ([]RDomain, error) {
-	contents, err := os.ReadFile("/etc/hosts")
+	contents, err := os.ReadFile(hostsPath())
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
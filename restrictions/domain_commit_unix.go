@@ -4,35 +4,120 @@ package restrictions
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/Despire/dnd/atomicfile"
 )
 
-const hostsFile = "/etc/hosts"
+func hostsPath() string { return rooted("/etc/hosts") }
 
-func (d *Diff) domainCommit() error {
-	b, err := os.ReadFile(hostsFile)
+// writeHosts atomically replaces the hosts file, keeping its mode and owner.
+func writeHosts(b []byte) error {
+	perm, uid, gid := os.FileMode(0644), os.Geteuid(), os.Getegid()
+	if fi, err := os.Stat(hostsPath()); err == nil {
+		perm = fi.Mode().Perm()
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			uid, gid = int(st.Uid), int(st.Gid)
+		}
+	}
+	if err := atomicfile.Write(hostsPath(), b, perm); err != nil {
+		return fmt.Errorf("failed to atomically write to %s: %w", hostsPath(), err)
+	}
+	// the file replacing the hosts file is owned by the writer.
+	if uid != os.Geteuid() || gid != os.Getegid() {
+		if err := os.Lchown(hostsPath(), uid, gid); err != nil {
+			return fmt.Errorf("failed to restore the owner of %s: %w", hostsPath(), err)
+		}
+	}
+	return nil
+}
+
+// Apply appends the guarded block to the hosts file. Without
+// root the privileged helper is asked to do it, see RunHelper.
+func (d RDomain) Apply() error {
+	return commitDomains(Diff{Type: Domain, Missing: []Restriction{d}})
+}
+
+// Revert removes the guarded block from the hosts file. Without
+// root the privileged helper is asked to do it, see RunHelper.
+func (d RDomain) Revert() error {
+	return commitDomains(Diff{Type: Domain, Delete: []Restriction{d}})
+}
+
+// commitSystem makes the changes of a commit that need root as a whole.
+// The current state is backed up first, if asked to, then the deleted
+// domain blocks are removed from the hosts file and the missing ones
+// appended with a single write, and the lock, if any, is stored. If
+// storing the lock fails, the hosts file is written back. Returns the
+// backup, if created.
+func commitSystem(d Diff, backup bool, lock *Lock, now time.Time) (*Backup, error) {
+	remove, err := domainBlocks(d.Delete)
 	if err != nil {
-		return fmt.Errorf("failed to open '/etc/hosts': %w", err)
+		return nil, err
+	}
+	add, err := domainBlocks(d.Missing)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, d := range d.Delete {
-		target := d.(RDomain).String()
-		i := bytes.Index(b, []byte(target))
-		if i < 0 {
-			continue // possible the file was changed...
+	var b *Backup
+	if backup {
+		created, err := backupPruned(now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up the current state: %w", err)
 		}
-		b = append(b[:i], b[i+len(target):]...)
+		b = &created
 	}
 
-	for _, d := range d.Missing {
-		b = append(b, d.(RDomain).String()...)
+	previous, err := os.ReadFile(hostsPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return b, fmt.Errorf("failed to open %s: %w", hostsPath(), err)
+	}
+	changed := editHosts(previous, remove, add)
+	if !bytes.Equal(changed, previous) {
+		if err := os.MkdirAll(filepath.Dir(hostsPath()), 0755); err != nil {
+			return b, fmt.Errorf("failed to create directory for %s: %w", hostsPath(), err)
+		}
+		if err := writeHosts(changed); err != nil {
+			return b, err
+		}
 	}
 
-	if err := atomicfile.Write("/etc/hosts", b, 0644); err != nil {
-		return fmt.Errorf("failed to atomically write to '/etc/hosts/: %w", err)
+	if lock != nil {
+		if err := StoreLock(*lock); err != nil {
+			errLock := fmt.Errorf("failed to store lock: %w", err)
+			if bytes.Equal(changed, previous) {
+				return b, errLock
+			}
+			if err := writeHosts(previous); err != nil {
+				return b, errors.Join(errLock, fmt.Errorf("failed to write %s back: %w", hostsPath(), err))
+			}
+			return b, errLock
+		}
 	}
-	return nil
+	return b, nil
+}
+
+// editHosts returns the contents of the hosts file without the
+// blocks to remove and with the ones to add, unless already present.
+func editHosts(b []byte, remove, add []RDomain) []byte {
+	b = bytes.Clone(b)
+	for _, d := range remove {
+		target := []byte(d.String())
+		if i := bytes.Index(b, target); i >= 0 {
+			b = append(b[:i], b[i+len(target):]...)
+		}
+		// possible the file was changed...
+	}
+	for _, d := range add {
+		if block := []byte(d.String()); !bytes.Contains(b, block) {
+			b = append(b, block...)
+		}
+	}
+	return b
 }
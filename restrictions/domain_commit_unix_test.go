@@ -0,0 +1,55 @@
+//go:build !windows
+
+package restrictions
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCommitDomainsAtOnce(t *testing.T) {
+	testRoot(t)
+
+	kept := NewDomain("kept.com", DefaultSinkhole)
+	deleted := NewDomain("deleted.com", DefaultSinkhole)
+	if err := os.MkdirAll(rooted("/etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	original := "127.0.0.1 localhost\n" + kept.String() + deleted.String()
+	if err := os.WriteFile(hostsPath(), []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	domains := Diff{
+		Type:    Domain,
+		Delete:  []Restriction{deleted},
+		Missing: []Restriction{NewDomain("a.com", DefaultSinkhole), NewDomain("b.com", DefaultSinkhole)},
+	}
+	failing := Diff{Type: testType, Missing: []Restriction{fakeRestriction{key: "x", failApply: true, calls: &calls}}}
+
+	if _, err := Commit([]Diff{domains, failing}, CommitOptions{}, time.Now()); !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Commit() error = %v, want %v", err, ErrRolledBack)
+	}
+	if b, _ := os.ReadFile(hostsPath()); string(b) != original {
+		t.Errorf("hosts after the rollback:\n%s\nwant:\n%s", b, original)
+	}
+
+	if _, err := Commit([]Diff{domains}, CommitOptions{}, time.Now()); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	synced, err := SyncDomains()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range synced {
+		got = append(got, d.Items()...)
+	}
+	if want := []string{"kept.com", "a.com", "b.com"}; !slices.Equal(got, want) {
+		t.Errorf("domains after the commit = %v, want %v", got, want)
+	}
+}
@@ -2,32 +2,49 @@
 
 package restrictions
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"time"
 
-const hostsFile = "TODO"
+	"github.com/Despire/dnd/atomicfile"
+)
 
-func (d *Diff) domainCommit() error {
+func hostsPath() string { return rooted(`C:\Windows\System32\drivers\etc\hosts`) }
+
+func writeHosts(b []byte) error {
+	if err := atomicfile.Write(hostsPath(), b, 0644); err != nil {
+		return fmt.Errorf("failed to atomically write to %s: %w", hostsPath(), err)
+	}
+	return nil
+}
+
+func (d RDomain) Apply() error {
+	return errors.New("not implemented")
+}
+
+func (d RDomain) Revert() error {
 	return errors.New("not implemented")
-	// b, err := os.ReadFile(hostsFile)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to open '/etc/hosts': %w", err)
-	// }
-
-	// for _, d := range d.Delete {
-	// 	target := d.(RDomain).String()
-	// 	i := bytes.Index(b, []byte(target))
-	// 	if i < 0 {
-	// 		continue // possible the file was changed...
-	// 	}
-	// 	b = append(b[:i], b[i+len(target):]...)
-	// }
-
-	// for _, d := range d.Missing {
-	// 	b = append(b, d.(RDomain).String()...)
-	// }
-
-	// if err := atomicfile.Write("/etc/hosts", b, 0644); err != nil {
-	// 	return fmt.Errorf("failed to atomically write to '/etc/hosts/: %w", err)
-	// }
-	// return nil
+}
+
+// commitSystem backs up the current state and stores the lock,
+// committing domain restrictions is not implemented.
+func commitSystem(d Diff, backup bool, lock *Lock, now time.Time) (*Backup, error) {
+	if len(d.Delete) > 0 || len(d.Missing) > 0 {
+		return nil, errors.New("not implemented")
+	}
+	var b *Backup
+	if backup {
+		created, err := backupPruned(now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up the current state: %w", err)
+		}
+		b = &created
+	}
+	if lock != nil {
+		if err := StoreLock(*lock); err != nil {
+			return b, fmt.Errorf("failed to store lock: %w", err)
+		}
+	}
+	return b, nil
 }
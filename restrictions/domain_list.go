@@ -0,0 +1,104 @@
+package restrictions
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// localHostnames are the names found in hosts files that
+// must never be blocked, as they refer to the machine itself.
+var localHostnames = []string{
+	"localhost",
+	"localhost.localdomain",
+	"local",
+	"broadcasthost",
+	"ip6-localhost",
+	"ip6-loopback",
+	"ip6-localnet",
+	"ip6-mcastprefix",
+	"ip6-allnodes",
+	"ip6-allrouters",
+	"ip6-allhosts",
+	"0.0.0.0",
+}
+
+// ParseDomainEntry extracts the domains from a single entry, which
+// can be a domain, a URL, a line of a hosts file or an adblock style
+// rule. Comments, adblock style exceptions and entries that refer to
+// the local machine are ignored. The returned domains are not yet
+// normalized.
+func ParseDomainEntry(entry string) []string {
+	entry = strings.TrimSpace(entry)
+	if i := strings.Index(entry, "#"); i >= 0 {
+		entry = strings.TrimSpace(entry[:i])
+	}
+	if entry == "" || strings.HasPrefix(entry, "!") || strings.HasPrefix(entry, "[") {
+		return nil
+	}
+	// adblock style exceptions, @@||example.com^, allow the domain.
+	if strings.HasPrefix(entry, "@@") {
+		return nil
+	}
+
+	// adblock style: ||example.com^ or ||example.com^$third-party
+	if rest, ok := strings.CutPrefix(entry, "||"); ok {
+		if i := strings.IndexAny(rest, "^$/"); i >= 0 {
+			rest = rest[:i]
+		}
+		return filterLocal([]string{rest})
+	}
+
+	fields := strings.Fields(entry)
+
+	// hosts file style: <ip> <domain> [<domain>...]
+	if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+		return filterLocal(fields[1:])
+	}
+
+	var domains []string
+	for _, f := range fields {
+		domains = append(domains, hostOf(f))
+	}
+	return filterLocal(domains)
+}
+
+// ReadDomainEntries reads one entry per line, see ParseDomainEntry.
+func ReadDomainEntries(r io.Reader) ([]string, error) {
+	var domains []string
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		domains = append(domains, ParseDomainEntry(s.Text())...)
+	}
+	return domains, s.Err()
+}
+
+// hostOf returns the host of the URL, or s unchanged if it is not a URL.
+func hostOf(s string) string {
+	if !strings.Contains(s, "://") {
+		// www.reddit.com/r/golang/ without a scheme.
+		if i := strings.IndexAny(s, "/?"); i >= 0 {
+			s = s[:i]
+		}
+		// example.com:8080 without a scheme.
+		if host, _, err := net.SplitHostPort(s); err == nil {
+			s = host
+		}
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" {
+		return s
+	}
+	return u.Hostname()
+}
+
+func filterLocal(domains []string) []string {
+	return slices.DeleteFunc(domains, func(d string) bool {
+		return d == "" || slices.Contains(localHostnames, strings.ToLower(d))
+	})
+}
@@ -0,0 +1,55 @@
+package restrictions
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDomainEntry(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  []string
+	}{
+		{entry: "example.com", want: []string{"example.com"}},
+		{entry: "  example.com  # comment", want: []string{"example.com"}},
+		{entry: "example.com other.com", want: []string{"example.com", "other.com"}},
+		{entry: "*.example.com", want: []string{"*.example.com"}},
+		{entry: "https://www.example.com/watch?v=1", want: []string{"www.example.com"}},
+		{entry: "http://example.com:8080/path", want: []string{"example.com"}},
+		{entry: "www.reddit.com/r/golang/", want: []string{"www.reddit.com"}},
+		{entry: "example.com:8080", want: []string{"example.com"}},
+		{entry: "example.com:8080/path", want: []string{"example.com"}},
+		{entry: "example.com?q=1", want: []string{"example.com"}},
+		{entry: "0.0.0.0 example.com", want: []string{"example.com"}},
+		{entry: "127.0.0.1 example.com www.example.com", want: []string{"example.com", "www.example.com"}},
+		{entry: "::1 localhost ip6-localhost", want: nil},
+		{entry: "127.0.0.1 localhost", want: nil},
+		{entry: "0.0.0.0 0.0.0.0", want: nil},
+		{entry: "||example.com^", want: []string{"example.com"}},
+		{entry: "||example.com^$third-party", want: []string{"example.com"}},
+		{entry: "||example.com/ads", want: []string{"example.com"}},
+		{entry: "@@||allow.com^", want: nil},
+		{entry: "@@||allow.com^$document", want: nil},
+		{entry: "! adblock comment", want: nil},
+		{entry: "[Adblock Plus 2.0]", want: nil},
+		{entry: "# hosts comment", want: nil},
+		{entry: "", want: nil},
+	}
+	for _, tt := range tests {
+		if got := ParseDomainEntry(tt.entry); !slices.Equal(got, tt.want) {
+			t.Errorf("ParseDomainEntry(%q) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestReadDomainEntries(t *testing.T) {
+	list := "# blocklist\n0.0.0.0 a.com\n||b.com^\n@@||c.com^\nhttps://d.com/\n\n"
+	got, err := ReadDomainEntries(strings.NewReader(list))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.com", "b.com", "d.com"}; !slices.Equal(got, want) {
+		t.Errorf("ReadDomainEntries() = %q, want %q", got, want)
+	}
+}
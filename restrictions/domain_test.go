@@ -0,0 +1,114 @@
+package restrictions
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		item  string
+		want  string
+		valid bool
+	}{
+		{item: "example.com", want: "example.com", valid: true},
+		{item: " Example.COM. ", want: "example.com", valid: true},
+		{item: "bücher.de", want: "xn--bcher-kva.de", valid: true},
+		{item: "BÜCHER.de", want: "xn--bcher-kva.de", valid: true},
+		{item: "xn--bcher-kva.de", want: "xn--bcher-kva.de", valid: true},
+		{item: "*.bücher.de", want: "*.xn--bcher-kva.de", valid: true},
+		{item: "пример.рф", want: "xn--e1afmkfd.xn--p1ai", valid: true},
+		{item: ""},
+		{item: "   "},
+		{item: "127.0.0.1"},
+		{item: "::1"},
+		{item: "localhost"},
+		{item: "api.localhost"},
+		{item: "exa mple.com"},
+		{item: "example..com"},
+		{item: "exa_mple.com"},
+		{item: "xn--zz.de"},
+		{item: "a" + strings.Repeat("b", 63) + ".com"},
+		{item: "*.youtube.com", want: "*.youtube.com", valid: true},
+		{item: "*.co.uk", want: "*.co.uk", valid: true},
+		{item: "*.com"},
+		{item: "*.com."},
+		{item: "*."},
+		{item: "*"},
+		{item: "*.*.example.com"},
+		{item: "www.*.example.com"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeDomain(tt.item)
+		if (err == nil) != tt.valid {
+			t.Errorf("NormalizeDomain(%q) error = %v, want valid %v", tt.item, err, tt.valid)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeDomain(%q) = %q, want %q", tt.item, got, tt.want)
+		}
+	}
+}
+
+func TestExpandDomain(t *testing.T) {
+	if got := ExpandDomain("example.com"); !slices.Equal(got, []string{"example.com"}) {
+		t.Errorf("ExpandDomain(example.com) = %v", got)
+	}
+
+	got := ExpandDomain("*.example.com")
+	if len(got) != len(WildcardSubdomains)+1 || got[0] != "example.com" {
+		t.Fatalf("ExpandDomain(*.example.com) = %v", got)
+	}
+	for i, sub := range WildcardSubdomains {
+		if want := sub + ".example.com"; got[i+1] != want {
+			t.Errorf("ExpandDomain(*.example.com)[%v] = %q, want %q", i+1, got[i+1], want)
+		}
+	}
+}
+
+func TestValidateSinkhole(t *testing.T) {
+	tests := []struct {
+		sinkhole Sinkhole
+		valid    bool
+	}{
+		{sinkhole: DefaultSinkhole, valid: true},
+		{sinkhole: Sinkhole{IPv4: "0.0.0.0", IPv6: "::"}, valid: true},
+		{sinkhole: Sinkhole{IPv4: "127.0.0.2"}, valid: true},
+		{sinkhole: Sinkhole{IPv4: "::1"}},
+		{sinkhole: Sinkhole{IPv6: "127.0.0.1"}},
+		{sinkhole: Sinkhole{IPv4: "10.0.0.1"}},
+		{sinkhole: Sinkhole{IPv4: "1.1.1.1"}},
+		{sinkhole: Sinkhole{IPv6: "2001:db8::1"}},
+		{sinkhole: Sinkhole{IPv4: "localhost"}},
+	}
+	for _, tt := range tests {
+		if err := ValidateSinkhole(tt.sinkhole); (err == nil) != tt.valid {
+			t.Errorf("ValidateSinkhole(%+v) error = %v, want valid %v", tt.sinkhole, err, tt.valid)
+		}
+	}
+}
+
+func TestRemoved(t *testing.T) {
+	block := NewDomain("example.com", DefaultSinkhole)
+	guard := block.Key()
+	ipv4 := RDomain{header: guard, footer: guard, Restrictions: block.Restrictions[:1]}
+
+	tests := []struct {
+		name    string
+		diff    Diff
+		removed int
+	}{
+		{name: "deleted", diff: Diff{Delete: []Restriction{block}}, removed: 1},
+		{name: "added", diff: Diff{Missing: []Restriction{block}}},
+		{name: "upgraded", diff: Diff{Delete: []Restriction{ipv4}, Missing: []Restriction{block}}},
+		{name: "shrunk", diff: Diff{Delete: []Restriction{block}, Missing: []Restriction{ipv4}}, removed: 1},
+		{name: "repointed", diff: Diff{Delete: []Restriction{block}, Missing: []Restriction{NewDomain("example.com", Sinkhole{IPv4: "0.0.0.0", IPv6: "::"})}}, removed: 1},
+		{name: "replaced by another", diff: Diff{Delete: []Restriction{block}, Missing: []Restriction{NewDomain("example.org", DefaultSinkhole)}}, removed: 1},
+	}
+	for _, tt := range tests {
+		if got := tt.diff.Removed(); len(got) != tt.removed {
+			t.Errorf("%s: Removed() = %v, want %v removed", tt.name, got, tt.removed)
+		}
+	}
+}
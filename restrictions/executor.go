@@ -0,0 +1,112 @@
+package restrictions
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Executor runs the external commands of the package, i.e. the
+// service managers loading the application restrictions.
+type Executor interface {
+	// Run runs the command and returns its combined output.
+	Run(name string, args ...string) ([]byte, error)
+	// RunInput runs the command with the input passed to the standard
+	// input and returns its standard output only, the standard error
+	// is reported with the error.
+	RunInput(input []byte, name string, args ...string) ([]byte, error)
+}
+
+// Command is an external command, see Recorder.
+type Command struct {
+	Name string
+	Args []string
+}
+
+// String returns the command as it would be typed in a shell.
+func (c Command) String() string {
+	words := []string{c.Name}
+	for _, a := range c.Args {
+		if a == "" || strings.ContainsAny(a, " \t\n\"'\\$`*?[]|&;<>()#~") {
+			a = strconv.Quote(a)
+		}
+		words = append(words, a)
+	}
+	return strings.Join(words, " ")
+}
+
+// Recorder is an Executor that records the commands
+// instead of running them, every command succeeds.
+type Recorder struct {
+	mu       sync.Mutex
+	commands []Command
+	// unsandbox, if set, maps the paths of a sandbox in the
+	// arguments back to the real ones, see Sandbox.
+	unsandbox *strings.Replacer
+}
+
+func (r *Recorder) Run(name string, args ...string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.unsandbox != nil {
+		args = slices.Clone(args)
+		for i, a := range args {
+			args[i] = r.unsandbox.Replace(a)
+		}
+	}
+	r.commands = append(r.commands, Command{Name: name, Args: args})
+	return nil, nil
+}
+
+func (r *Recorder) RunInput(_ []byte, name string, args ...string) ([]byte, error) {
+	return r.Run(name, args...)
+}
+
+// Commands returns the recorded commands and clears them.
+func (r *Recorder) Commands() []Command {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	commands := r.commands
+	r.commands = nil
+	return commands
+}
+
+type osExecutor struct{}
+
+func (osExecutor) Run(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).CombinedOutput()
+}
+
+func (osExecutor) RunInput(input []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(input)
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+var executor Executor = osExecutor{}
+
+// SetExecutor replaces the executor the external commands are run
+// with, returning the previous one.
+func SetExecutor(e Executor) Executor {
+	previous := executor
+	executor = e
+	return previous
+}
+
+// run runs the command with the executor in use.
+func run(name string, args ...string) ([]byte, error) { return executor.Run(name, args...) }
+
+// runInput runs the command with the input with the executor in use.
+func runInput(input []byte, name string, args ...string) ([]byte, error) {
+	return executor.RunInput(input, name, args...)
+}
@@ -0,0 +1,292 @@
+package restrictions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Operations of the privileged helper.
+const (
+	// commits the domain blocks along with the backup and the lock, see commitSystem.
+	helperCommit = "commit"
+	// backs up the current state, see CreateBackup.
+	helperBackup = "backup"
+	// stores the lock, see StoreLock.
+	helperLock = "lock"
+)
+
+// maxHelperRequest limits the size of a request, large
+// enough for the blocks of a sizeable blocklist.
+const maxHelperRequest = 64 << 20
+
+// ErrInvalidRequest is returned by the helper for a request it refuses.
+var ErrInvalidRequest = errors.New("invalid helper request")
+
+// guardPattern matches the header and footer of a domain block, see NewDomain.
+var guardPattern = regexp.MustCompile(`^` + DndDomainPrefix + `[0-9a-f]{32}\n$`)
+
+// HelperRequest is a single privileged operation requested by an
+// unprivileged dnd from the helper, see RunHelper.
+type HelperRequest struct {
+	Op string
+	// Delete and Missing are the domain blocks to
+	// remove from and to append to the hosts file.
+	Delete  []string `json:",omitempty"`
+	Missing []string `json:",omitempty"`
+	// Groups are the names of the groups among
+	// the missing blocks, see NewDomainGroup.
+	Groups []string `json:",omitempty"`
+	// Backup backs up the current state before the commit.
+	Backup bool `json:",omitempty"`
+	// Lock is the lock to store.
+	Lock *Lock `json:",omitempty"`
+	// Applying is the process of the requesting dnd, marked as
+	// changing the restrictions before the commit, see MarkApplying.
+	Applying int `json:",omitempty"`
+}
+
+// RunHelper serves the request read from in, the backup created, if any,
+// is written to out. Run as root on behalf of an unprivileged dnd, the
+// request is validated before changing anything. Only guarded blocks
+// pointing valid domains to a loopback or unspecified address are
+// applied, guarded by the key of their item or of a named group, see
+// checkGuard. Only guarded blocks are deleted, none while the stored
+// lock is active unless replaced by an updated version of themselves.
+func RunHelper(in io.Reader, out io.Writer) error {
+	if os.Geteuid() > 0 {
+		return errors.New("the helper must be run as root")
+	}
+
+	var req HelperRequest
+	if err := json.NewDecoder(io.LimitReader(in, maxHelperRequest)).Decode(&req); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+	}
+
+	switch req.Op {
+	case helperCommit:
+		var d Diff
+		for _, block := range req.Delete {
+			r, err := parseBlock(block, false)
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+			}
+			d.Delete = append(d.Delete, r)
+		}
+		for _, block := range req.Missing {
+			r, err := parseBlock(block, true)
+			if err == nil {
+				r, err = checkGuard(r, req.Groups)
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidRequest, err)
+			}
+			d.Missing = append(d.Missing, r)
+		}
+		if removed := d.Removed(); len(removed) > 0 {
+			if err := CheckLock(nil, time.Now()); err != nil {
+				return fmt.Errorf("%w, refusing to delete %v domain restrictions", err, len(removed))
+			}
+		}
+
+		if req.Applying > 0 {
+			if err := markApplying(req.Applying, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		b, err := commitSystem(d, req.Backup, req.Lock, time.Now())
+		if b != nil {
+			// reported even if the commit failed, the backup was created.
+			if err := json.NewEncoder(out).Encode(b); err != nil {
+				return err
+			}
+		}
+		return err
+	case helperBackup:
+		b, err := backupPruned(time.Now())
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(out).Encode(b)
+	case helperLock:
+		if req.Lock == nil {
+			return fmt.Errorf("%w: missing lock", ErrInvalidRequest)
+		}
+		return StoreLock(*req.Lock)
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidRequest, req.Op)
+	}
+}
+
+// parseBlock parses the single guarded domain block. The contents
+// of a block to apply are validated, see RunHelper.
+func parseBlock(block string, apply bool) (RDomain, error) {
+	lines := strings.SplitAfter(block, "\n")
+	if len(lines) < 3 || lines[len(lines)-1] != "" {
+		return RDomain{}, errors.New("not a guarded domain block")
+	}
+	lines = lines[:len(lines)-1]
+
+	d := RDomain{header: lines[0], footer: lines[len(lines)-1]}
+	if d.header != d.footer || !guardPattern.MatchString(d.header) {
+		return RDomain{}, errors.New("not a guarded domain block")
+	}
+
+	for i, line := range lines[1 : len(lines)-1] {
+		if strings.HasPrefix(line, DndDomainPrefix) {
+			return RDomain{}, fmt.Errorf("line %v: nested guard", i+2)
+		}
+		fields := strings.Fields(line)
+		if apply {
+			if err := validHostsLine(fields); err != nil {
+				return RDomain{}, fmt.Errorf("line %v: %w", i+2, err)
+			}
+		}
+		entry := struct {
+			IP      string
+			Domains []string
+			Raw     string
+		}{Raw: line}
+		if len(fields) > 0 {
+			entry.IP, entry.Domains = fields[0], fields[1:]
+		}
+		d.Restrictions = append(d.Restrictions, entry)
+	}
+	return d, nil
+}
+
+// checkGuard checks that the block to apply is guarded by the key of
+// the item it blocks, see NewDomain, so that it cannot pass for an
+// updated version of another block. A block of a group, whose key
+// cannot be derived from its domains, must be guarded by the key of
+// one of the groups, see NewDomainGroup. Returns the block named by
+// its group, if any.
+func checkGuard(d RDomain, groups []string) (RDomain, error) {
+	for _, name := range groups {
+		if NewDomainGroup(name, nil, DefaultSinkhole).Key() == d.Key() {
+			d.group = name
+			return d, nil
+		}
+	}
+	items := d.Items()
+	if len(items) == 0 {
+		return d, errors.New("empty domain block")
+	}
+	// the block of a wildcard starts with its base domain, see ExpandDomain.
+	for _, item := range []string{items[0], "*." + items[0]} {
+		if NewDomain(item, DefaultSinkhole).Key() != d.Key() {
+			continue
+		}
+		expanded := ExpandDomain(item)
+		for _, domain := range items {
+			if !slices.Contains(expanded, domain) {
+				return d, fmt.Errorf("domain %s is not blocked by %s", domain, item)
+			}
+		}
+		return d, nil
+	}
+	return d, fmt.Errorf("the guard doesn't match the block of %s", items[0])
+}
+
+// validHostsLine checks that the line of the hosts file
+// only points normalized domains to a sinkhole address.
+func validHostsLine(fields []string) error {
+	if len(fields) < 2 {
+		return errors.New("expected an address followed by domains")
+	}
+	if err := sinkholeAddress(fields[0]); err != nil {
+		return err
+	}
+	for _, domain := range fields[1:] {
+		if normalized, err := NormalizeDomain(domain); err != nil || normalized != domain {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+	}
+	return nil
+}
+
+// elevator returns the program used to run the helper as root.
+func elevator() (string, error) {
+	for _, name := range []string{"sudo", "pkexec"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", errors.New("root is required, but neither sudo nor pkexec was found")
+}
+
+// callHelper runs the request with the helper elevated to root and
+// returns its output. The user may be asked to authenticate by sudo
+// or pkexec. The output is returned even if the helper failed.
+func callHelper(req HelperRequest) ([]byte, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the dnd executable: %w", err)
+	}
+	elevate, err := elevator()
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	out, err := runInput(b, elevate, exe, "helper")
+	if err != nil {
+		return out, fmt.Errorf("privileged helper failed to %s: %w", req.Op, err)
+	}
+	return out, nil
+}
+
+// requestCommit makes the changes of a commit that need root, see
+// commitSystem, without root with a single request to the helper.
+func requestCommit(d Diff, backup bool, lock *Lock, now time.Time) (*Backup, error) {
+	if privileged() && !sandboxHelper {
+		return commitSystem(d, backup, lock, now)
+	}
+
+	remove, err := domainBlocks(d.Delete)
+	if err != nil {
+		return nil, err
+	}
+	add, err := domainBlocks(d.Missing)
+	if err != nil {
+		return nil, err
+	}
+	req := HelperRequest{Op: helperCommit, Backup: backup, Lock: lock, Applying: os.Getpid()}
+	for _, r := range remove {
+		req.Delete = append(req.Delete, r.String())
+	}
+	for _, r := range add {
+		req.Missing = append(req.Missing, r.String())
+		if r.group != "" {
+			req.Groups = append(req.Groups, r.group)
+		}
+	}
+	out, err := callHelper(req)
+	// recorded by a sandbox, see Sandbox, the changes are made in it.
+	if sandboxHelper {
+		if err != nil {
+			return nil, err
+		}
+		return commitSystem(d, backup, lock, now)
+	}
+
+	var b *Backup
+	if len(bytes.TrimSpace(out)) > 0 {
+		b = new(Backup)
+		if errDecode := json.Unmarshal(out, b); errDecode != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to decode the backup created by the helper: %w", errDecode))
+		}
+	}
+	return b, err
+}
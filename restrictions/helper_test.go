@@ -0,0 +1,201 @@
+package restrictions
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBlock(t *testing.T) {
+	block := NewDomain("example.com", DefaultSinkhole).String()
+	guard := strings.SplitAfter(block, "\n")[0]
+	other := strings.SplitAfter(NewDomain("other.com", DefaultSinkhole).String(), "\n")[0]
+
+	tests := []struct {
+		name  string
+		block string
+		apply bool
+		valid bool
+	}{
+		{name: "valid", block: block, apply: true, valid: true},
+		{name: "empty", block: "", apply: true},
+		{name: "missing footer", block: guard + "127.0.0.1 example.com\n", apply: true},
+		{name: "missing trailing newline", block: strings.TrimSuffix(block, "\n"), apply: true},
+		{name: "mismatched footer", block: guard + "127.0.0.1 example.com\n" + other, apply: true},
+		{name: "not a guard", block: "#dndxyz\n127.0.0.1 example.com\n#dndxyz\n", apply: true},
+		{name: "nested guard", block: guard + other + "127.0.0.1 example.com\n" + other + guard, apply: true},
+		{name: "nested guard reverted", block: guard + other + guard, apply: false},
+		{name: "non-loopback address", block: guard + "10.0.0.1 example.com\n" + guard, apply: true},
+		{name: "non-loopback address reverted", block: guard + "10.0.0.1 example.com\n" + guard, apply: false, valid: true},
+		{name: "unnormalized domain", block: guard + "127.0.0.1 Example.COM\n" + guard, apply: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := parseBlock(tt.block, tt.apply)
+			if (err == nil) != tt.valid {
+				t.Fatalf("parseBlock() error = %v, want valid %v", err, tt.valid)
+			}
+			if err == nil && d.String() != tt.block {
+				t.Errorf("parseBlock() = %q, want %q", d.String(), tt.block)
+			}
+		})
+	}
+}
+
+func TestCheckGuard(t *testing.T) {
+	block := func(r RDomain) RDomain {
+		d, err := parseBlock(r.String(), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	guard := NewDomain("example.com", DefaultSinkhole).Key()
+	forged, err := parseBlock(guard+"127.0.0.1 unrelated.com\n"+guard, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extended, err := parseBlock(guard+"127.0.0.1 example.com\n127.0.0.1 unrelated.com\n"+guard, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		block  RDomain
+		groups []string
+		valid  bool
+	}{
+		{name: "domain", block: block(NewDomain("example.com", DefaultSinkhole)), valid: true},
+		{name: "wildcard", block: block(NewDomain("*.example.com", DefaultSinkhole)), valid: true},
+		{name: "other sinkhole", block: block(NewDomain("example.com", Sinkhole{IPv4: "0.0.0.0", IPv6: "::"})), valid: true},
+		{name: "group", block: block(NewDomainGroup("ads", []string{"a.com", "b.com"}, DefaultSinkhole)), groups: []string{"ads"}, valid: true},
+		{name: "unnamed group", block: block(NewDomainGroup("ads", []string{"a.com", "b.com"}, DefaultSinkhole))},
+		{name: "forged", block: forged},
+		{name: "forged group", block: forged, groups: []string{"example.com"}},
+		{name: "extended", block: extended},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := checkGuard(tt.block, tt.groups); (err == nil) != tt.valid {
+				t.Errorf("checkGuard() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidHostsLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		valid bool
+	}{
+		{line: "127.0.0.1 example.com", valid: true},
+		{line: "::1 example.com www.example.com", valid: true},
+		{line: "0.0.0.0 example.com", valid: true},
+		{line: ":: example.com", valid: true},
+		{line: "127.0.0.1"},
+		{line: ""},
+		{line: "10.0.0.1 example.com"},
+		{line: "1.1.1.1 example.com"},
+		{line: "localhost example.com"},
+		{line: "127.0.0.1 Example.com"},
+		{line: "127.0.0.1 example.com."},
+		{line: "127.0.0.1 bücher.de"},
+		{line: "127.0.0.1 exa_mple.com"},
+		{line: "127.0.0.1 #comment"},
+	}
+	for _, tt := range tests {
+		if err := validHostsLine(strings.Fields(tt.line)); (err == nil) != tt.valid {
+			t.Errorf("validHostsLine(%q) error = %v, want valid %v", tt.line, err, tt.valid)
+		}
+	}
+}
+
+func TestRunHelperCommit(t *testing.T) {
+	if os.Geteuid() > 0 {
+		t.Skip("the helper must be run as root")
+	}
+	testRoot(t)
+
+	// written before the IPv6 sinkhole was introduced.
+	guard := NewDomain("kept.com", DefaultSinkhole).Key()
+	kept := guard + "127.0.0.1 kept.com\n" + guard
+	if err := os.MkdirAll(filepath.Dir(hostsPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(hostsPath(), []byte(kept), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	helper := func(req HelperRequest) (string, error) {
+		b, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := new(bytes.Buffer)
+		err = RunHelper(bytes.NewReader(b), out)
+		return out.String(), err
+	}
+
+	added := NewDomain("added.com", DefaultSinkhole)
+	lock := &Lock{Until: time.Now().Add(time.Hour)}
+	out, err := helper(HelperRequest{Op: helperCommit, Missing: []string{added.String()}, Backup: true, Lock: lock})
+	if err != nil {
+		t.Fatalf("commit error = %v", err)
+	}
+	var b Backup
+	if err := json.Unmarshal([]byte(out), &b); err != nil || b.ID == "" {
+		t.Errorf("commit output = %q, want the backup: %v", out, err)
+	}
+	if stored, err := ReadStoredLock(); err != nil || stored == nil || !stored.Until.Equal(lock.Until) {
+		t.Errorf("stored lock = %v, %v, want %v", stored, err, lock)
+	}
+	want := kept + added.String()
+	if hosts, _ := os.ReadFile(hostsPath()); string(hosts) != want {
+		t.Fatalf("hosts = %q, want %q", hosts, want)
+	}
+
+	// while locked, a block is only deleted if replaced by an updated
+	// version still pointing all of its domains to the same addresses,
+	// guarded by the key of the item it blocks.
+	forged := added.Key() + "127.0.0.1 unrelated.com\n" + added.Key()
+	_, err = helper(HelperRequest{Op: helperCommit, Delete: []string{added.String()}, Missing: []string{forged}})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("forged replacement while locked error = %v, want %v", err, ErrInvalidRequest)
+	}
+	if hosts, _ := os.ReadFile(hostsPath()); string(hosts) != want {
+		t.Fatalf("hosts = %q, want %q", hosts, want)
+	}
+	_, err = helper(HelperRequest{Op: helperCommit, Delete: []string{kept}})
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("delete while locked error = %v, want %v", err, ErrLocked)
+	}
+	repointed := NewDomain("kept.com", Sinkhole{IPv4: "0.0.0.0", IPv6: "::"})
+	_, err = helper(HelperRequest{Op: helperCommit, Delete: []string{kept}, Missing: []string{repointed.String()}})
+	if !errors.Is(err, ErrLocked) {
+		t.Errorf("sinkhole change while locked error = %v, want %v", err, ErrLocked)
+	}
+	upgraded := NewDomain("kept.com", DefaultSinkhole)
+	if _, err := helper(HelperRequest{Op: helperCommit, Delete: []string{kept}, Missing: []string{upgraded.String()}}); err != nil {
+		t.Errorf("upgrade while locked error = %v", err)
+	}
+	want = added.String() + upgraded.String()
+	if hosts, _ := os.ReadFile(hostsPath()); string(hosts) != want {
+		t.Errorf("hosts = %q, want %q", hosts, want)
+	}
+
+	// nothing is changed if any of the blocks is invalid.
+	invalid := strings.Replace(NewDomain("invalid.com", DefaultSinkhole).String(), "127.0.0.1", "10.0.0.1", 1)
+	_, err = helper(HelperRequest{Op: helperCommit, Missing: []string{NewDomain("valid.com", DefaultSinkhole).String(), invalid}})
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("invalid block error = %v, want %v", err, ErrInvalidRequest)
+	}
+	if hosts, _ := os.ReadFile(hostsPath()); string(hosts) != want {
+		t.Errorf("hosts = %q, want %q", hosts, want)
+	}
+}
@@ -0,0 +1,97 @@
+package restrictions
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrVersionNotFound is returned for a version missing from the history.
+var ErrVersionNotFound = errors.New("version not found in the history")
+
+// HistoryEntry is a version of the config recorded in the history.
+type HistoryEntry struct {
+	Version int64
+	Time    time.Time
+	User    string
+	// Command is the command line that wrote the version.
+	Command string
+	// Config as written, without the last commited config.
+	Config Config
+}
+
+func HistoryPath() string { return filepath.Join(filepath.Dir(ConfigPath()), ".history") }
+
+// appendHistory appends the config to the history, one entry per line.
+func appendHistory(c Config) error {
+	c.LastCommited = nil
+	e := HistoryEntry{
+		Version: c.Version,
+		Time:    time.Now(),
+		User:    invokingUser(),
+		Command: strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " "),
+		Config:  c,
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err := chownToInvokingUser(HistoryPath()); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory returns the recorded versions of the config, oldest
+// first. Lines that can't be decoded, i.e. a line cut short by a
+// crash, are skipped.
+func ReadHistory() ([]HistoryEntry, error) {
+	f, err := os.Open(HistoryPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []HistoryEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 64<<20)
+	for s.Scan() {
+		var e HistoryEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history %s: %w", HistoryPath(), err)
+	}
+	return entries, nil
+}
+
+// LookupVersion returns the latest entry of the history with the version.
+func LookupVersion(history []HistoryEntry, version int64) (HistoryEntry, error) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Version == version {
+			return history[i], nil
+		}
+	}
+	return HistoryEntry{}, fmt.Errorf("%w: %v", ErrVersionNotFound, version)
+}
@@ -0,0 +1,143 @@
+package restrictions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/Despire/dnd/atomicfile"
+)
+
+// ErrLocked is returned when restrictions would be removed while a lock is active.
+var ErrLocked = errors.New("restrictions are locked")
+
+// Lock prevents the commited restrictions from being
+// removed until the deadline passes.
+type Lock struct {
+	Until time.Time
+}
+
+// StateDir returns the directory for the state of the program
+// that must not be modifiable by an unprivileged user.
+func StateDir() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return rooted("/var/db/dnd")
+	case "windows":
+		return rooted(`C:\ProgramData\dnd`)
+	default:
+		return rooted("/var/lib/dnd")
+	}
+}
+
+func lockPath() string { return filepath.Join(StateDir(), "lock") }
+
+// ReadStoredLock reads the lock stored under the StateDir, returns
+// nil if no lock was ever stored.
+func ReadStoredLock() (*Lock, error) {
+	b, err := os.ReadFile(lockPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var l Lock
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("failed to decode lock %s: %w", lockPath(), err)
+	}
+	return &l, nil
+}
+
+// StoreLock stores the lock under the StateDir, so that it survives
+// changes to the user config. A stored lock is never shortened.
+// Without root the privileged helper is asked to do it.
+func StoreLock(l Lock) error {
+	current, err := ReadStoredLock()
+	if err != nil {
+		return err
+	}
+	if current != nil && !current.Until.Before(l.Until) {
+		return nil
+	}
+	if !privileged() {
+		_, err := callHelper(HelperRequest{Op: helperLock, Lock: &l})
+		return err
+	}
+	if err := os.MkdirAll(StateDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", StateDir(), err)
+	}
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(lockPath(), b, 0644); err != nil {
+		return fmt.Errorf("failed to atomically write lock: %w", err)
+	}
+	return nil
+}
+
+// LockedUntil returns the deadline of the lock active at the given
+// time, considering both the lock in the config, if any, and the
+// stored lock. A stored lock that can't be read fails closed, it is
+// reported as locked along with the error.
+func LockedUntil(c *Config, now time.Time) (time.Time, bool, error) {
+	var until time.Time
+	if c != nil && c.Lock != nil {
+		until = c.Lock.Until
+	}
+	stored, err := ReadStoredLock()
+	if err != nil {
+		return until, true, fmt.Errorf("failed to read the stored lock: %w", err)
+	}
+	if stored != nil && stored.Until.After(until) {
+		until = stored.Until
+	}
+	return until, until.After(now), nil
+}
+
+// Loosens reports whether replacing c by next lifts any of the
+// restrictions of the profile in use, which the lock forbids the same
+// way it forbids deleting them: an item removed or only in effect
+// during a different schedule, a subscription removed or the domains
+// pointed to another sinkhole.
+func Loosens(c, next *Config) bool {
+	if c.Sinkhole.OrDefault() != next.Sinkhole.OrDefault() {
+		return true
+	}
+	for t, l := range c.Restrictions {
+		items := next.Restrictions[t].Items()
+		for _, item := range l.Items() {
+			if !slices.Contains(items, item) {
+				return true
+			}
+			if s := next.Schedules[t][item]; s != "" && s != c.Schedules[t][item] {
+				return true
+			}
+		}
+	}
+	for name := range c.Subscriptions {
+		if _, ok := next.Subscriptions[name]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckLock returns ErrLocked if a lock is active at the given time,
+// see LockedUntil.
+func CheckLock(c *Config, now time.Time) error {
+	until, locked, err := LockedUntil(c, now)
+	switch {
+	case err != nil:
+		return fmt.Errorf("%w, %w", ErrLocked, err)
+	case locked:
+		return fmt.Errorf("%w until %s", ErrLocked, until.Format(time.DateTime))
+	}
+	return nil
+}
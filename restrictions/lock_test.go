@@ -0,0 +1,39 @@
+package restrictions
+
+import "testing"
+
+func TestLoosens(t *testing.T) {
+	config := func(items List, schedule Schedule, subscriptions ...string) *Config {
+		c := &Config{Profile: Profile{Restrictions: map[Type]List{Domain: items}}}
+		if schedule != "" {
+			c.SetSchedule(Domain, "a.com", schedule)
+		}
+		for _, name := range subscriptions {
+			if c.Subscriptions == nil {
+				c.Subscriptions = make(map[string]*Subscription)
+			}
+			c.Subscriptions[name] = &Subscription{}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name      string
+		c, next   *Config
+		wantLoose bool
+	}{
+		{name: "unchanged", c: config("a.com,b.com", ""), next: config("a.com,b.com", "")},
+		{name: "added", c: config("a.com", ""), next: config("a.com,b.com", "")},
+		{name: "removed", c: config("a.com,b.com", ""), next: config("a.com", ""), wantLoose: true},
+		{name: "scheduled", c: config("a.com", ""), next: config("a.com", "09:00-17:00"), wantLoose: true},
+		{name: "rescheduled", c: config("a.com", "09:00-17:00"), next: config("a.com", "10:00-17:00"), wantLoose: true},
+		{name: "unscheduled", c: config("a.com", "09:00-17:00"), next: config("a.com", "")},
+		{name: "subscribed", c: config("a.com", ""), next: config("a.com", "", "ads")},
+		{name: "unsubscribed", c: config("a.com", "", "ads"), next: config("a.com", ""), wantLoose: true},
+	}
+	for _, tt := range tests {
+		if got := Loosens(tt.c, tt.next); got != tt.wantLoose {
+			t.Errorf("%s: Loosens() = %v, want %v", tt.name, got, tt.wantLoose)
+		}
+	}
+}
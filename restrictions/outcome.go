@@ -0,0 +1,100 @@
+package restrictions
+
+import (
+	"errors"
+	"slices"
+)
+
+// Outcome of committing a single item.
+type Outcome string
+
+const (
+	// the item is in effect.
+	OutcomeApplied Outcome = "applied"
+	// the item was not commited, e.g. the commit was declined,
+	// or the item is not in effect at the time of the commit.
+	OutcomeSkipped Outcome = "skipped"
+	// commiting the item failed.
+	OutcomeFailed Outcome = "failed"
+)
+
+// Result is the outcome of committing a single item.
+type Result struct {
+	Outcome Outcome
+	// Error is the reason the item was skipped or failed.
+	Error string `json:",omitempty"`
+}
+
+// Results of committing the items of the list. The items whose restriction
+// is matched by the diff, or left in effect by a commit that failed to roll
+// back, see PartialCommitError, are applied. The ones whose restriction
+// failed to commit as reported by err of Commit are failed, and all the
+// others have the given result.
+func (d *Diff) Results(c *Config, l List, result Result, err error) map[string]Result {
+	k, ok := KindOf(d.Type)
+	if !ok {
+		return nil
+	}
+
+	inEffect := make(map[string]bool)
+	var partial *PartialCommitError
+	if errors.As(err, &partial) {
+		for _, changes := range partial.InEffect {
+			if changes.Type != d.Type {
+				continue
+			}
+			for _, r := range changes.Missing {
+				inEffect[r.Key()] = true
+			}
+		}
+	}
+
+	failed := make(map[string]error)
+	for _, step := range StepErrors(err) {
+		if step.Type != d.Type {
+			continue
+		}
+		restrictions := []Restriction{step.Restriction}
+		if step.Restriction == nil {
+			// the whole difference failed to commit.
+			restrictions = slices.Concat(d.Delete, d.Missing)
+		}
+		for _, r := range restrictions {
+			// the first error is the cause, later ones are of the rollback.
+			if _, ok := failed[r.Key()]; !ok {
+				failed[r.Key()] = step.Err
+			}
+		}
+	}
+
+	results := make(map[string]Result)
+	for _, item := range l.Items() {
+		key := k.New(c, item).Key()
+		switch {
+		case inEffect[key]:
+			results[item] = Result{Outcome: OutcomeApplied}
+		case failed[key] != nil:
+			results[item] = Result{Outcome: OutcomeFailed, Error: failed[key].Error()}
+		case slices.ContainsFunc(d.Matched, func(r Restriction) bool { return r.Key() == key }):
+			results[item] = Result{Outcome: OutcomeApplied}
+		default:
+			results[item] = result
+		}
+	}
+	return results
+}
+
+// Failed returns the items of the type that failed to
+// commit at the last commit with their errors.
+func (c *Config) Failed(t Type) map[string]string {
+	if c.LastCommited == nil {
+		return nil
+	}
+	failed := make(map[string]string)
+	for item, r := range c.LastCommited.Results[t] {
+		if r.Outcome == OutcomeFailed {
+			failed[item] = r.Error
+		}
+	}
+	return failed
+}
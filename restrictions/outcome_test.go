@@ -0,0 +1,95 @@
+//go:build !windows
+
+package restrictions
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// hostsBreaker fails to apply after replacing the hosts file
+// with a directory, so that the domains cannot be rolled back.
+type hostsBreaker struct{ fakeRestriction }
+
+func (b hostsBreaker) Apply() error {
+	if err := os.Remove(hostsPath()); err != nil {
+		return err
+	}
+	if err := os.Mkdir(hostsPath(), 0755); err != nil {
+		return err
+	}
+	return b.fakeRestriction.Apply()
+}
+
+func TestResultsOfPartialCommit(t *testing.T) {
+	testRoot(t)
+	if err := os.MkdirAll(rooted("/etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	matched := NewDomain("matched.com", DefaultSinkhole)
+	if err := os.WriteFile(hostsPath(), []byte(matched.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{}
+	newDomain := func(item string) Restriction { return NewDomain(item, DefaultSinkhole) }
+	domains := Diff{
+		Type:    Domain,
+		Matched: []Restriction{matched},
+		Missing: []Restriction{newDomain("a.com"), newDomain("b.com")},
+	}
+	var calls []string
+	failing := Diff{Type: testType, Missing: []Restriction{
+		hostsBreaker{fakeRestriction{key: "x", failApply: true, calls: &calls}},
+	}}
+
+	_, err := Commit([]Diff{domains, failing}, CommitOptions{}, time.Now())
+	var partial *PartialCommitError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Commit() error = %v, want a %T", err, partial)
+	}
+	if len(partial.InEffect) != 1 || partial.InEffect[0].Type != Domain || len(partial.InEffect[0].Missing) != 2 {
+		t.Fatalf("InEffect = %+v, want the domains", partial.InEffect)
+	}
+	steps := StepErrors(err)
+	if len(steps) != 2 || steps[0].Type != testType || !steps[0].Applying || steps[1].Type != Domain || steps[1].Restriction != nil {
+		t.Fatalf("StepErrors() = %v, want the apply of x and the rollback of the domains", steps)
+	}
+
+	l := List("matched.com,a.com,b.com,other.com")
+	results := domains.Results(c, l, Result{Outcome: OutcomeSkipped, Error: "rolled back"}, err)
+	want := map[string]Outcome{
+		"matched.com": OutcomeApplied,
+		"a.com":       OutcomeApplied,
+		"b.com":       OutcomeApplied,
+		"other.com":   OutcomeSkipped,
+	}
+	for item, outcome := range want {
+		if results[item].Outcome != outcome {
+			t.Errorf("outcome of %s = %v, want %v", item, results[item], outcome)
+		}
+	}
+}
+
+func TestResultsOfRolledBackCommit(t *testing.T) {
+	testRoot(t)
+	if err := os.MkdirAll(rooted("/etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{}
+	domains := Diff{Type: Domain, Missing: []Restriction{NewDomain("a.com", DefaultSinkhole)}}
+	var calls []string
+	failing := Diff{Type: testType, Missing: []Restriction{fakeRestriction{key: "x", failApply: true, calls: &calls}}}
+
+	_, err := Commit([]Diff{domains, failing}, CommitOptions{}, time.Now())
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Commit() error = %v, want %v", err, ErrRolledBack)
+	}
+	results := domains.Results(c, List("a.com"), Result{Outcome: OutcomeSkipped, Error: "rolled back"}, err)
+	if results["a.com"].Outcome != OutcomeSkipped {
+		t.Errorf("outcome of a.com = %v, want %v", results["a.com"], OutcomeSkipped)
+	}
+}
@@ -0,0 +1,77 @@
+package restrictions
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+)
+
+// invoker returns the user that elevated the program to root
+// with sudo or pkexec, nil if it was not elevated.
+func invoker() *user.User {
+	if os.Geteuid() != 0 {
+		return nil
+	}
+	for _, env := range []string{"SUDO_UID", "PKEXEC_UID"} {
+		uid := os.Getenv(env)
+		if uid == "" || uid == "0" {
+			continue
+		}
+		if u, err := user.LookupId(uid); err == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// Elevated reports whether the program runs as root elevated by a
+// user with sudo or pkexec, the user whose restrictions apply.
+func Elevated() bool { return invoker() != nil }
+
+// privileged reports whether the files of the system, i.e. the hosts
+// file, can be written directly instead of through the helper. Always
+// the case under a root, as the files of the tree belong to the user.
+func privileged() bool { return root != "" || os.Geteuid() <= 0 }
+
+// chownToInvokingUser gives the files to the user that elevated the
+// program, so that the files written under its home while running as
+// root stay usable without root.
+func chownToInvokingUser(paths ...string) error {
+	u := invoker()
+	if u == nil {
+		return nil
+	}
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return err
+	}
+	var errChown error
+	for _, p := range paths {
+		if err := os.Lchown(p, uid, gid); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errChown = errors.Join(errChown, err)
+		}
+	}
+	return errChown
+}
+
+// mkdirAllOwned is os.MkdirAll giving the created
+// directories to the user that elevated the program.
+func mkdirAllOwned(dir string, perm os.FileMode) error {
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		created = append(created, d)
+	}
+	if err := os.MkdirAll(dir, perm); err != nil {
+		return err
+	}
+	return chownToInvokingUser(created...)
+}
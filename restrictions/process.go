@@ -0,0 +1,42 @@
+package restrictions
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Process is a process running on the OS.
+type Process struct {
+	PID int
+	// Cmdline is the full command line of the process
+	// with the arguments separated by a single space.
+	Cmdline string
+}
+
+// Kill sends SIGKILL to the process.
+func (p Process) Kill() error {
+	proc, err := os.FindProcess(p.PID)
+	if err != nil {
+		return err
+	}
+	return proc.Kill()
+}
+
+// ProcessMatcher matches the command lines of running processes
+// the same way `pkill -i -f <pattern>` does, used by the application
+// restrictions.
+type ProcessMatcher struct {
+	Pattern string
+	re      *regexp.Regexp
+}
+
+func NewProcessMatcher(pattern string) (ProcessMatcher, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return ProcessMatcher{}, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return ProcessMatcher{Pattern: pattern, re: re}, nil
+}
+
+func (m ProcessMatcher) Match(p Process) bool { return m.re.MatchString(p.Cmdline) }
@@ -0,0 +1,35 @@
+//go:build darwin
+
+package restrictions
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Processes returns the processes currently running on the OS
+// as reported by ps.
+func Processes() ([]Process, error) {
+	output, err := run("ps", "-axww", "-o", "pid=,command=")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+
+	var procs []Process
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		pid, cmdline, ok := strings.Cut(strings.TrimSpace(s.Text()), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(pid)
+		if err != nil {
+			continue
+		}
+		procs = append(procs, Process{PID: n, Cmdline: strings.TrimSpace(cmdline)})
+	}
+	return procs, s.Err()
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package restrictions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Processes returns the processes currently running on the OS
+// by scanning /proc. Kernel threads and processes that exited
+// during the scan are skipped.
+func Processes() ([]Process, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join("/proc", e.Name(), "cmdline"))
+		if err != nil || len(b) == 0 {
+			continue
+		}
+		b = bytes.TrimRight(b, "\x00")
+		b = bytes.ReplaceAll(b, []byte{0}, []byte{' '})
+		procs = append(procs, Process{PID: pid, Cmdline: string(b)})
+	}
+	return procs, nil
+}
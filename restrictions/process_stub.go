@@ -0,0 +1,9 @@
+//go:build !darwin && !linux
+
+package restrictions
+
+import "errors"
+
+func Processes() ([]Process, error) {
+	return nil, errors.New("not implemented")
+}
@@ -0,0 +1,198 @@
+package restrictions
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// DefaultProfile is the name of the profile in use
+// when no other profile was ever selected.
+const DefaultProfile = "default"
+
+var (
+	// ErrProfileNotFound is returned when the requested profile does not exist.
+	ErrProfileNotFound = errors.New("profile not found")
+
+	// ErrProfileExists is returned when creating a profile that already exists.
+	ErrProfileExists = errors.New("profile already exists")
+
+	// ErrProfileInUse is returned when deleting the profile that is in use.
+	ErrProfileInUse = errors.New("profile is in use")
+)
+
+// Profile is a named set of restrictions.
+type Profile struct {
+	// Currently stored restrictions.
+	Restrictions map[Type]List
+	// Schedules of the restrictions that are only in effect during
+	// the specified time windows, keyed by the item of the restriction.
+	// Items without a schedule are always in effect.
+	Schedules map[Type]map[string]Schedule `json:",omitempty"`
+}
+
+// Clone returns a deep copy of the profile.
+func (p *Profile) Clone() *Profile {
+	c := &Profile{
+		Restrictions: maps.Clone(p.Restrictions),
+	}
+	if c.Restrictions == nil {
+		c.Restrictions = make(map[Type]List)
+	}
+	for t, s := range p.Schedules {
+		if c.Schedules == nil {
+			c.Schedules = make(map[Type]map[string]Schedule)
+		}
+		c.Schedules[t] = maps.Clone(s)
+	}
+	return c
+}
+
+// Active returns the restrictions that are in effect at the given time.
+func (p *Profile) Active(now time.Time) map[Type]List {
+	active := make(map[Type]List)
+	for t, l := range p.Restrictions {
+		var current List
+		for _, item := range l.Items() {
+			if p.Schedules[t][item].Active(now) {
+				current = current.Append(item)
+			}
+		}
+		if !current.Empty() {
+			active[t] = current
+		}
+	}
+	return active
+}
+
+// Scheduled returns the scheduled restrictions of the given type
+// and whether they are in effect at the given time.
+func (p *Profile) Scheduled(t Type, now time.Time) []Scheduled {
+	var scheduled []Scheduled
+	for _, item := range p.Restrictions[t].Items() {
+		if s, ok := p.Schedules[t][item]; ok {
+			scheduled = append(scheduled, Scheduled{
+				Item:     item,
+				Schedule: s,
+				Active:   s.Active(now),
+			})
+		}
+	}
+	return scheduled
+}
+
+// SetSchedule sets the schedule for the item of the given type.
+// An empty schedule removes any previously set schedule.
+func (p *Profile) SetSchedule(t Type, item string, s Schedule) {
+	if s == "" {
+		delete(p.Schedules[t], item)
+		if len(p.Schedules[t]) == 0 {
+			delete(p.Schedules, t)
+		}
+		return
+	}
+	if p.Schedules == nil {
+		p.Schedules = make(map[Type]map[string]Schedule)
+	}
+	if p.Schedules[t] == nil {
+		p.Schedules[t] = make(map[string]Schedule)
+	}
+	p.Schedules[t][item] = s
+}
+
+// CurrentProfile returns the name of the profile in use.
+func (c *Config) CurrentProfile() string {
+	if c.Current == "" {
+		return DefaultProfile
+	}
+	return c.Current
+}
+
+// ProfileNames returns the sorted names of all profiles.
+func (c *Config) ProfileNames() []string {
+	names := slices.Collect(maps.Keys(c.Profiles))
+	names = append(names, c.CurrentProfile())
+	slices.Sort(names)
+	return names
+}
+
+// Lookup returns the profile with the given name. An empty
+// name refers to the profile in use.
+func (c *Config) Lookup(name string) (*Profile, error) {
+	if name == "" || name == c.CurrentProfile() {
+		if c.Restrictions == nil {
+			c.Restrictions = make(map[Type]List)
+		}
+		return &c.Profile, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	if p.Restrictions == nil {
+		p.Restrictions = make(map[Type]List)
+	}
+	return p, nil
+}
+
+// CreateProfile creates a new profile without any restrictions.
+func (c *Config) CreateProfile(name string) error {
+	if _, err := c.Lookup(name); err == nil {
+		return fmt.Errorf("%w: %s", ErrProfileExists, name)
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	c.Profiles[name] = &Profile{Restrictions: make(map[Type]List)}
+	return nil
+}
+
+// CopyProfile creates a new profile with the restrictions of an existing one.
+func (c *Config) CopyProfile(src, dst string) error {
+	p, err := c.Lookup(src)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Lookup(dst); err == nil {
+		return fmt.Errorf("%w: %s", ErrProfileExists, dst)
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	c.Profiles[dst] = p.Clone()
+	return nil
+}
+
+// DeleteProfile deletes a profile that is not in use.
+func (c *Config) DeleteProfile(name string) error {
+	if name == c.CurrentProfile() {
+		return fmt.Errorf("%w: %s", ErrProfileInUse, name)
+	}
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	delete(c.Profiles, name)
+	return nil
+}
+
+// UseProfile makes the profile with the given name the one in use.
+// The restrictions of the previous profile are kept under its name.
+func (c *Config) UseProfile(name string) error {
+	if name == c.CurrentProfile() {
+		return nil
+	}
+	next, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	c.Profiles[c.CurrentProfile()] = c.Profile.Clone()
+	delete(c.Profiles, name)
+	c.Profile = *next.Clone()
+	c.Current = name
+	return nil
+}
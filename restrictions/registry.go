@@ -0,0 +1,143 @@
+package restrictions
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Kind describes a type of restrictions. Every type registers its
+// Kind, which drives the diffing, commiting and the command line.
+type Kind struct {
+	// Type identifies the kind in the config, it must never change.
+	Type Type
+	// Name of the kind as used on the command line.
+	Name string
+	// Help describes the accepted items.
+	Help string
+	// Parse validates and normalizes a single entry given on the command
+	// line, returning the items to store. An error is returned for the
+	// invalid parts of the entry, the valid items are returned still.
+	Parse func(entry string) ([]string, error)
+	// Candidates, if set, proposes items for an entry to choose from.
+	Candidates func(item string) []Candidate
+	// Sync returns the restrictions found at the OS level.
+	Sync func() ([]Restriction, error)
+	// New creates the restriction for a single item with
+	// the settings of the config, i.e. the sinkhole.
+	New func(c *Config, item string) Restriction
+	// Extra, if set, returns the restrictions that are wanted in
+	// addition to the ones created for the items.
+	Extra func(c *Config) ([]Restriction, error)
+	// Watch, if set, returns the files and directories where the
+	// restrictions are enforced, any modification of them is
+	// checked for tampering.
+	Watch func() []string
+	// Backup, if set, copies the files where the restrictions
+	// are enforced to the directory of a backup.
+	Backup func(dir string) error
+	// Restore, if set, brings the restrictions back to the
+	// state stored in the directory of a backup.
+	Restore func(dir string) error
+	// Commit, if set, commits the whole difference of the kind at once
+	// instead of reverting and applying every restriction on its own,
+	// i.e. all domain blocks with a single write of the hosts file.
+	// Either all of the difference is commited or none of it.
+	Commit func(d Diff) error
+	// Privileged is set if committing the kind needs root. Its difference
+	// is commited first, along with the backup and the lock, without root
+	// with a single request to the privileged helper, see Commit. Exactly
+	// one kind is privileged and it sets Commit.
+	Privileged bool
+}
+
+// Candidate is an item proposed for an entry given on the command line.
+type Candidate struct {
+	Item        string
+	Description string
+}
+
+var (
+	registryLock sync.RWMutex
+	registry     = make(map[Type]Kind)
+)
+
+// Register makes the kind available. Panics if the
+// type or the name is already registered.
+func Register(k Kind) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if k.Type == Invalid {
+		panic(fmt.Sprintf("restrictions: kind %s uses the invalid type", k.Name))
+	}
+	if _, ok := registry[k.Type]; ok {
+		panic(fmt.Sprintf("restrictions: type %d registered twice", k.Type))
+	}
+	for _, other := range registry {
+		if strings.EqualFold(other.Name, k.Name) {
+			panic(fmt.Sprintf("restrictions: kind %s registered twice", k.Name))
+		}
+		if k.Privileged && other.Privileged {
+			panic(fmt.Sprintf("restrictions: kind %s is privileged, so is %s", k.Name, other.Name))
+		}
+	}
+	if k.Privileged && k.Commit == nil {
+		panic(fmt.Sprintf("restrictions: privileged kind %s doesn't commit", k.Name))
+	}
+	registry[k.Type] = k
+}
+
+// Kinds returns all registered kinds ordered by their type.
+func Kinds() []Kind {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	var kinds []Kind
+	for _, t := range slices.Sorted(maps.Keys(registry)) {
+		kinds = append(kinds, registry[t])
+	}
+	return kinds
+}
+
+// KindOf returns the kind registered for the type.
+func KindOf(t Type) (Kind, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	k, ok := registry[t]
+	return k, ok
+}
+
+// privilegedKind returns the kind committed with root, see Kind.Privileged.
+func privilegedKind() (Kind, bool) {
+	for _, k := range Kinds() {
+		if k.Privileged {
+			return k, true
+		}
+	}
+	return Kind{}, false
+}
+
+// ParseType returns the type of the kind with the given name, case insensitive.
+func ParseType(name string) (Type, error) {
+	name = strings.TrimSpace(name)
+	for _, k := range Kinds() {
+		if strings.EqualFold(k.Name, name) {
+			return k.Type, nil
+		}
+	}
+	return Invalid, fmt.Errorf("invalid type %v", name)
+}
+
+func (t Type) String() string {
+	if k, ok := KindOf(t); ok {
+		return k.Name
+	}
+	if t == Invalid {
+		return "Invalid"
+	}
+	return fmt.Sprintf("Type(%d)", uint8(t))
+}
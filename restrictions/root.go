@@ -0,0 +1,53 @@
+package restrictions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// root every path of the package is rebased onto, empty for the real filesystem.
+var root string
+
+// SetRoot rebases every path read or written by the package onto the
+// directory, i.e. the hosts file becomes <dir>/etc/hosts and the config
+// <dir>/<home>/.dnd_config/.config. An empty directory restores the real
+// filesystem. Combined with a Recorder, see SetExecutor, the restrictions
+// can be commited to a fake tree without privileges.
+func SetRoot(dir string) error {
+	if dir == "" {
+		root = ""
+		return nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid root %q: %w", dir, err)
+	}
+	root = abs
+	return nil
+}
+
+// Root returns the directory set by SetRoot.
+func Root() string { return root }
+
+// rooted rebases the absolute path onto the root.
+func rooted(path string) string {
+	if root == "" {
+		return path
+	}
+	return filepath.Join(root, path[len(filepath.VolumeName(path)):])
+}
+
+// homeDir returns the home directory of the user, rebased onto the root.
+// If elevated to root, the user is the one that elevated the program.
+// Empty if it can't be determined.
+func homeDir() string {
+	if u := invoker(); u != nil && u.HomeDir != "" {
+		return rooted(u.HomeDir)
+	}
+	h, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return rooted(h)
+}
@@ -0,0 +1,31 @@
+package restrictions
+
+import "testing"
+
+// testRoot rebases every path onto a temporary directory and records
+// the external commands instead of running them, for the duration of
+// the test.
+func testRoot(t *testing.T) (string, *Recorder) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", "/home/dnd")
+	if err := SetRoot(dir); err != nil {
+		t.Fatal(err)
+	}
+	rec := new(Recorder)
+	previous := SetExecutor(rec)
+	t.Cleanup(func() {
+		SetExecutor(previous)
+		SetRoot("")
+	})
+	return dir, rec
+}
+
+// commandStrings returns the commands as typed in a shell.
+func commandStrings(commands []Command) []string {
+	var out []string
+	for _, c := range commands {
+		out = append(out, c.String())
+	}
+	return out
+}
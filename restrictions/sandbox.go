@@ -0,0 +1,86 @@
+package restrictions
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sandboxHelper is set in a sandbox of a program without root, whose
+// requests to the helper are recorded while made in the sandbox.
+var sandboxHelper bool
+
+// sandboxed returns the paths copied into a sandbox.
+func sandboxed() []string {
+	paths := []string{filepath.Dir(ConfigPath()), lockPath()}
+	for _, k := range Kinds() {
+		if k.Watch != nil {
+			paths = append(paths, k.Watch()...)
+		}
+	}
+	return paths
+}
+
+// Sandbox copies the files where the restrictions are enforced, the
+// config directory and the stored lock into a temporary root, see
+// SetRoot, and records the external commands instead of running them,
+// until the returned function is called. Changes made meanwhile don't
+// affect the system, i.e. to explain what a commit would do.
+func Sandbox() (*Recorder, func(), error) {
+	tmp, err := os.MkdirTemp("", "dnd-sandbox")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create sandbox: %w", err)
+	}
+
+	previousRoot := Root()
+	sources := sandboxed()
+	// the helper a real commit would run is recorded too.
+	needsHelper := !privileged()
+	if err := SetRoot(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return nil, nil, err
+	}
+	rec := &Recorder{unsandbox: strings.NewReplacer(Root(), previousRoot)}
+	previousExecutor := SetExecutor(rec)
+	sandboxHelper = needsHelper
+
+	restore := func() {
+		sandboxHelper = false
+		SetExecutor(previousExecutor)
+		SetRoot(previousRoot)
+		os.RemoveAll(tmp)
+	}
+
+	for i, dst := range sandboxed() {
+		if err := copyTree(dst, sources[i]); err != nil {
+			restore()
+			return nil, nil, fmt.Errorf("failed to copy %s into the sandbox: %w", sources[i], err)
+		}
+	}
+	return rec, restore, nil
+}
+
+// copyTree copies the file, or the directory recursively,
+// a missing source is not an error.
+func copyTree(dst, src string) error {
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		return copyFile(filepath.Join(dst, rel), path)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
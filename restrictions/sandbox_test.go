@@ -0,0 +1,62 @@
+package restrictions
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSandboxRecordsRealPaths(t *testing.T) {
+	dir, _ := testRoot(t)
+
+	rec, restore, err := Sandbox()
+	if err != nil {
+		t.Fatalf("Sandbox() error = %v", err)
+	}
+	defer restore()
+	if Root() == dir {
+		t.Fatal("Sandbox() did not change the root")
+	}
+
+	run("launchctl", "bootstrap", "gui/501", rooted("/Library/LaunchAgents/com.dnd.plist"))
+	want := []string{"launchctl bootstrap gui/501 " + filepath.Join(dir, "Library", "LaunchAgents", "com.dnd.plist")}
+	if got := commandStrings(rec.Commands()); !slices.Equal(got, want) {
+		t.Errorf("commands in the sandbox = %q, want %q", got, want)
+	}
+}
+
+func TestSandboxRecordsHelper(t *testing.T) {
+	_, rec := testRoot(t)
+	// only looked up, the recorder never runs it.
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "sudo"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	elevate, err := elevator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// as in a sandbox of a program without root.
+	sandboxHelper = true
+	t.Cleanup(func() { sandboxHelper = false })
+
+	added := NewDomain("example.com", DefaultSinkhole)
+	if _, err := requestCommit(Diff{Type: Domain, Missing: []Restriction{added}}, false, nil, time.Now()); err != nil {
+		t.Fatalf("requestCommit() error = %v", err)
+	}
+	want := []string{Command{Name: elevate, Args: []string{exe, "helper"}}.String()}
+	if got := commandStrings(rec.Commands()); !slices.Equal(got, want) {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+	if hosts, _ := os.ReadFile(hostsPath()); !strings.Contains(string(hosts), added.String()) {
+		t.Errorf("hosts = %q, want the block added in the sandbox", hosts)
+	}
+}
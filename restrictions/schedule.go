@@ -0,0 +1,148 @@
+package restrictions
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Schedule describes the time windows during which a restriction
+// is in effect. It is a list of windows separated with ';' where
+// each window is in the form "<days> <HH:MM>-<HH:MM>", for example
+// "Mon-Fri 09:00-17:30; Sat,Sun 10:00-12:00". The days may be
+// omitted, in which case the window applies to every day. A window
+// whose end is before its start spans over midnight.
+type Schedule string
+
+type window struct {
+	days  [7]bool // indexed by time.Weekday
+	start int     // minutes since midnight
+	end   int     // minutes since midnight
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// ParseSchedule validates and normalizes the given schedule.
+func ParseSchedule(s string) (Schedule, error) {
+	var normalized []string
+	for _, w := range strings.Split(s, ";") {
+		w = strings.Join(strings.Fields(w), " ")
+		if w == "" {
+			continue
+		}
+		if _, err := parseWindow(w); err != nil {
+			return "", fmt.Errorf("invalid schedule window %q: %w", w, err)
+		}
+		normalized = append(normalized, w)
+	}
+	if len(normalized) == 0 {
+		return "", errors.New("empty schedule")
+	}
+	return Schedule(strings.Join(normalized, "; ")), nil
+}
+
+// Active reports whether any of the windows of the schedule
+// contains t. An empty schedule is always active.
+func (s Schedule) Active(t time.Time) bool {
+	if s == "" {
+		return true
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range strings.Split(string(s), ";") {
+		win, err := parseWindow(strings.TrimSpace(w))
+		if err != nil {
+			continue
+		}
+		if win.start <= win.end {
+			if win.days[today] && minutes >= win.start && minutes < win.end {
+				return true
+			}
+			continue
+		}
+		// spans over midnight.
+		if win.days[today] && minutes >= win.start {
+			return true
+		}
+		if win.days[yesterday] && minutes < win.end {
+			return true
+		}
+	}
+	return false
+}
+
+func parseWindow(w string) (window, error) {
+	var win window
+
+	days, span, ok := strings.Cut(w, " ")
+	if !ok {
+		days, span = "*", w
+	}
+
+	if err := parseDays(&win, strings.ToLower(days)); err != nil {
+		return window{}, err
+	}
+
+	from, to, ok := strings.Cut(span, "-")
+	if !ok {
+		return window{}, fmt.Errorf("expected time range in the form HH:MM-HH:MM, got %q", span)
+	}
+
+	var err error
+	if win.start, err = parseClock(from); err != nil {
+		return window{}, err
+	}
+	if win.end, err = parseClock(to); err != nil {
+		return window{}, err
+	}
+	if win.start == win.end {
+		return window{}, fmt.Errorf("empty time range %q", span)
+	}
+	return win, nil
+}
+
+func parseDays(win *window, days string) error {
+	if days == "*" || days == "daily" {
+		for i := range win.days {
+			win.days[i] = true
+		}
+		return nil
+	}
+
+	for _, part := range strings.Split(days, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start := slices.Index(weekdays, from)
+		if start < 0 {
+			return fmt.Errorf("unknown day %q", from)
+		}
+		end := start
+		if isRange {
+			if end = slices.Index(weekdays, to); end < 0 {
+				return fmt.Errorf("unknown day %q", to)
+			}
+		}
+		for i := start; ; i = (i + 1) % 7 {
+			win.days[i] = true
+			if i == end {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,86 @@
+package restrictions
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     Schedule
+		valid    bool
+	}{
+		{schedule: "Mon-Fri 09:00-17:30", want: "Mon-Fri 09:00-17:30", valid: true},
+		{schedule: " mon-fri   09:00-17:30 ;; Sat,Sun 10:00-12:00; ", want: "mon-fri 09:00-17:30; Sat,Sun 10:00-12:00", valid: true},
+		{schedule: "22:00-06:00", want: "22:00-06:00", valid: true},
+		{schedule: "daily 00:00-24:00", want: "daily 00:00-24:00", valid: true},
+		{schedule: "Sat-Mon 09:00-10:00", want: "Sat-Mon 09:00-10:00", valid: true},
+		{schedule: ""},
+		{schedule: " ; "},
+		{schedule: "09:00-09:00"},
+		{schedule: "Mon 09:00"},
+		{schedule: "Mon 25:00-26:00"},
+		{schedule: "Mon 09:00-09:60"},
+		{schedule: "Mon 9am-5pm"},
+		{schedule: "Mon-Xyz 09:00-10:00"},
+		{schedule: "Monday 09:00-10:00"},
+		{schedule: "Mon 09:00-10:00; Tue"},
+	}
+	for _, tt := range tests {
+		got, err := ParseSchedule(tt.schedule)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseSchedule(%q) error = %v, want valid %v", tt.schedule, err, tt.valid)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSchedule(%q) = %q, want %q", tt.schedule, got, tt.want)
+		}
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	// 2026-10-12 is a Monday.
+	at := func(day int, clock string) time.Time {
+		c, err := time.Parse("15:04", clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2026, time.October, 12+day, c.Hour(), c.Minute(), 0, 0, time.Local)
+	}
+	const mon, tue, fri, sat, sun = 0, 1, 4, 5, 6
+
+	tests := []struct {
+		name     string
+		schedule Schedule
+		t        time.Time
+		want     bool
+	}{
+		{name: "empty", schedule: "", t: at(mon, "03:00"), want: true},
+		{name: "within", schedule: "Mon-Fri 09:00-17:30", t: at(tue, "09:00"), want: true},
+		{name: "end is exclusive", schedule: "Mon-Fri 09:00-17:30", t: at(tue, "17:30")},
+		{name: "other day", schedule: "Mon-Fri 09:00-17:30", t: at(sat, "10:00")},
+		{name: "any day", schedule: "09:00-17:30", t: at(sun, "10:00"), want: true},
+		{name: "until midnight", schedule: "Mon 20:00-24:00", t: at(mon, "23:59"), want: true},
+		{name: "over midnight before", schedule: "Mon 22:00-06:00", t: at(mon, "23:00"), want: true},
+		{name: "over midnight after", schedule: "Mon 22:00-06:00", t: at(tue, "05:59"), want: true},
+		{name: "over midnight end", schedule: "Mon 22:00-06:00", t: at(tue, "06:00")},
+		{name: "over midnight starts the day before", schedule: "Mon 22:00-06:00", t: at(mon, "05:00")},
+		{name: "over midnight not started that day", schedule: "Mon 22:00-06:00", t: at(tue, "23:00")},
+		{name: "over midnight into the next week", schedule: "Sun 22:00-06:00", t: at(mon, "01:00"), want: true},
+		{name: "over midnight into sunday", schedule: "Sat 22:00-06:00", t: at(sun, "01:00"), want: true},
+		{name: "over midnight into friday", schedule: "Fri 22:00-06:00", t: at(fri, "01:00")},
+		{name: "days over the end of the week", schedule: "Sat-Mon 09:00-10:00", t: at(sun, "09:30"), want: true},
+		{name: "days over the end of the week start", schedule: "Sat-Mon 09:00-10:00", t: at(mon, "09:30"), want: true},
+		{name: "days over the end of the week outside", schedule: "Sat-Mon 09:00-10:00", t: at(tue, "09:30")},
+		{name: "any of the windows", schedule: "Mon 09:00-10:00; Tue 11:00-12:00", t: at(tue, "11:30"), want: true},
+		{name: "none of the windows", schedule: "Mon 09:00-10:00; Tue 11:00-12:00", t: at(tue, "09:30")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Active(tt.t); got != tt.want {
+				t.Errorf("%q.Active(%v) = %v, want %v", tt.schedule, tt.t, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package restrictions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/Despire/dnd/atomicfile"
+)
+
+// SubscriptionMaxAge is the age after which the cached
+// copy of a subscription is refreshed on commit.
+const SubscriptionMaxAge = 24 * time.Hour
+
+// maxSubscriptionSize limits the size of a downloaded blocklist.
+const maxSubscriptionSize = 64 << 20
+
+var subscriptionName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Subscription is a remote or local blocklist whose
+// domains are blocked as a single group.
+type Subscription struct {
+	// Source is the URL or the path of the blocklist.
+	Source string
+	// ETag and LastModified of the last successful
+	// download, used for conditional requests.
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	// Checksum is the sha256 of the cached copy.
+	Checksum string
+	// Refreshed is the time of the last successful refresh.
+	Refreshed time.Time
+}
+
+// ValidateSubscriptionName checks that the name can be used for the cached copy.
+func ValidateSubscriptionName(name string) error {
+	if !subscriptionName.MatchString(name) {
+		return fmt.Errorf("invalid subscription name %q, expected lowercase letters, digits, '-' or '_'", name)
+	}
+	return nil
+}
+
+func SubscriptionsDir() string { return filepath.Join(filepath.Dir(ConfigPath()), "subscriptions") }
+
+func subscriptionCache(name string) string { return filepath.Join(SubscriptionsDir(), name+".list") }
+
+// Refresh downloads the blocklist and replaces the cached copy. On
+// failure the previously cached copy is kept. Reports whether the
+// contents of the blocklist changed.
+func (s *Subscription) Refresh(name string) (bool, error) {
+	contents, header, notModified, err := s.fetch()
+	if err != nil {
+		return false, fmt.Errorf("failed to refresh subscription %s from %s: %w", name, s.Source, err)
+	}
+
+	now := time.Now()
+	if notModified {
+		s.Refreshed = now
+		return false, nil
+	}
+
+	digest := sha256.Sum256(contents)
+	checksum := hex.EncodeToString(digest[:])
+	if checksum == s.Checksum {
+		if _, err := os.Stat(subscriptionCache(name)); err == nil {
+			s.setValidators(header)
+			s.Refreshed = now
+			return false, nil
+		}
+	}
+
+	if err := mkdirAllOwned(SubscriptionsDir(), os.ModePerm); err != nil {
+		return false, fmt.Errorf("failed to create directory %s: %w", SubscriptionsDir(), err)
+	}
+	if err := atomicfile.Write(subscriptionCache(name), contents, 0644); err != nil {
+		return false, fmt.Errorf("failed to cache subscription %s: %w", name, err)
+	}
+	if err := chownToInvokingUser(subscriptionCache(name)); err != nil {
+		return false, fmt.Errorf("failed to give the cache of subscription %s to the invoking user: %w", name, err)
+	}
+
+	// only once cached, otherwise a conditional request would keep
+	// reporting the contents that failed to be cached as up to date.
+	s.setValidators(header)
+	s.Checksum = checksum
+	s.Refreshed = now
+	return true, nil
+}
+
+// setValidators remembers the ETag and Last-Modified of the response
+// for the conditional requests, if the source was fetched over HTTP.
+func (s *Subscription) setValidators(header http.Header) {
+	if header == nil {
+		return
+	}
+	s.ETag = header.Get("ETag")
+	s.LastModified = header.Get("Last-Modified")
+}
+
+// fetch reads the blocklist from the source. For HTTP sources a
+// conditional request is made, reporting notModified if the server
+// says the cached copy is up to date, and the header of the response
+// is returned.
+func (s *Subscription) fetch() (contents []byte, header http.Header, notModified bool, err error) {
+	u, err := url.Parse(s.Source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		path := s.Source
+		if err == nil && u.Scheme == "file" {
+			path = u.Path
+		}
+		contents, err := os.ReadFile(path)
+		return contents, nil, false, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, s.Source, nil)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if s.ETag != "" {
+		req.Header.Set("If-None-Match", s.ETag)
+	}
+	if s.LastModified != "" {
+		req.Header.Set("If-Modified-Since", s.LastModified)
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return nil, resp.Header, true, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, nil, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	contents, err = io.ReadAll(io.LimitReader(resp.Body, maxSubscriptionSize+1))
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if len(contents) > maxSubscriptionSize {
+		return nil, nil, false, fmt.Errorf("blocklist exceeds %v bytes", maxSubscriptionSize)
+	}
+	return contents, resp.Header, false, nil
+}
+
+// Domains returns the normalized domains of the cached copy.
+// Invalid entries are skipped.
+func (s *Subscription) Domains(name string) ([]string, error) {
+	contents, err := os.ReadFile(subscriptionCache(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached subscription %s: %w", name, err)
+	}
+
+	digest := sha256.Sum256(contents)
+	if s.Checksum != "" && hex.EncodeToString(digest[:]) != s.Checksum {
+		return nil, fmt.Errorf("checksum mismatch of cached subscription %s, refresh it", name)
+	}
+
+	entries, err := ReadDomainEntries(bytes.NewReader(contents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cached subscription %s: %w", name, err)
+	}
+
+	var domains []string
+	seen := make(map[string]struct{})
+	for _, e := range entries {
+		d, err := NormalizeDomain(e)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[d]; ok {
+			continue
+		}
+		seen[d] = struct{}{}
+		domains = append(domains, d)
+	}
+	return domains, nil
+}
+
+// NewDomainGroup returns a single restriction blocking all of the
+// domains, guarded by the name of the group.
+func NewDomainGroup(name string, domains []string, sinkhole Sinkhole) RDomain {
+	digest := sha512.Sum512([]byte("group:" + name))
+	guard := fmt.Sprintf("%s%s\n", DndDomainPrefix, hex.EncodeToString(digest[:16]))
+
+	r := RDomain{
+		header: guard,
+		footer: guard,
+		group:  name,
+	}
+	for _, item := range domains {
+		r.Restrictions = append(r.Restrictions, NewDomain(item, sinkhole).Restrictions...)
+	}
+	return r
+}
+
+// SubscriptionGroups returns the domain restrictions of all
+// subscriptions, one group per subscription. A subscription without
+// a cached copy, i.e. never refreshed, is skipped, keeping its group
+// in the hosts file as is, and reported with ErrPartialSync.
+func (c *Config) SubscriptionGroups() ([]RDomain, error) {
+	var groups []RDomain
+	var errSkipped error
+	for _, name := range slices.Sorted(maps.Keys(c.Subscriptions)) {
+		domains, err := c.Subscriptions[name].Domains(name)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			errSkipped = errors.Join(errSkipped, fmt.Errorf("skipped subscription %s, it was never refreshed", name))
+			current, err := SyncDomains()
+			if err != nil {
+				return nil, err
+			}
+			key := NewDomainGroup(name, nil, c.Sinkhole.OrDefault()).Key()
+			if i := slices.IndexFunc(current, func(d RDomain) bool { return d.Key() == key }); i >= 0 {
+				groups = append(groups, current[i])
+			}
+			continue
+		case err != nil:
+			return nil, err
+		}
+		groups = append(groups, NewDomainGroup(name, domains, c.Sinkhole.OrDefault()))
+	}
+	if errSkipped != nil {
+		return groups, fmt.Errorf("%w: %w", ErrPartialSync, errSkipped)
+	}
+	return groups, nil
+}
+
+// SubscriptionNames returns the sorted names of the subscriptions.
+func (c *Config) SubscriptionNames() []string {
+	return slices.Sorted(maps.Keys(c.Subscriptions))
+}
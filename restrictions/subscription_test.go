@@ -0,0 +1,223 @@
+package restrictions
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testBlocklist = `# test blocklist
+0.0.0.0 ads.example.com
+||Tracker.example.org^$third-party
+https://malware.example.net/path
+ads.example.com
+0.0.0.0 localhost
+`
+
+var testBlocklistDomains = []string{"ads.example.com", "tracker.example.org", "malware.example.net"}
+
+func TestSubscriptionRefreshHTTP(t *testing.T) {
+	testRoot(t)
+
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testBlocklist))
+	}))
+	defer srv.Close()
+
+	s := &Subscription{Source: srv.URL}
+	changed, err := s.Refresh("ads")
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if !changed || s.ETag != `"v1"` || s.Checksum == "" || s.Refreshed.IsZero() {
+		t.Fatalf("Refresh() = %v, subscription %+v", changed, s)
+	}
+
+	domains, err := s.Domains("ads")
+	if err != nil {
+		t.Fatalf("Domains() error = %v", err)
+	}
+	if !slices.Equal(domains, testBlocklistDomains) {
+		t.Errorf("Domains() = %v, want %v", domains, testBlocklistDomains)
+	}
+
+	changed, err = s.Refresh("ads")
+	if err != nil {
+		t.Fatalf("second Refresh() error = %v", err)
+	}
+	if changed || requests != 2 {
+		t.Errorf("second Refresh() = %v after %v requests, want a not modified conditional request", changed, requests)
+	}
+}
+
+func TestSubscriptionRefreshFailureKeepsCache(t *testing.T) {
+	testRoot(t)
+
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(testBlocklist))
+	}))
+	defer srv.Close()
+
+	s := &Subscription{Source: srv.URL}
+	if _, err := s.Refresh("ads"); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	fail = true
+	if _, err := s.Refresh("ads"); err == nil {
+		t.Fatal("Refresh() of an unavailable source succeeded")
+	}
+	if domains, err := s.Domains("ads"); err != nil || len(domains) != len(testBlocklistDomains) {
+		t.Errorf("Domains() = %v, %v, want the previously cached copy", domains, err)
+	}
+}
+
+func TestSubscriptionRefreshFile(t *testing.T) {
+	testRoot(t)
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(testBlocklist), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{path, "file://" + path} {
+		s := &Subscription{Source: source}
+		if _, err := s.Refresh("local"); err != nil {
+			t.Fatalf("Refresh(%s) error = %v", source, err)
+		}
+		domains, err := s.Domains("local")
+		if err != nil {
+			t.Fatalf("Domains() error = %v", err)
+		}
+		if !slices.Equal(domains, testBlocklistDomains) {
+			t.Errorf("Domains() of %s = %v, want %v", source, domains, testBlocklistDomains)
+		}
+	}
+}
+
+func TestSubscriptionDomainsChecksumMismatch(t *testing.T) {
+	testRoot(t)
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(testBlocklist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &Subscription{Source: path}
+	if _, err := s.Refresh("local"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(subscriptionCache("local"), []byte("evil.example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Domains("local"); err == nil {
+		t.Error("Domains() of a modified cache succeeded")
+	}
+}
+
+func TestSubscriptionGroupsSkipsMissingCache(t *testing.T) {
+	testRoot(t)
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(testBlocklist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{Subscriptions: map[string]*Subscription{
+		"cached": {Source: path},
+		"never":  {Source: "https://example.invalid/list"},
+	}}
+	if _, err := c.Subscriptions["cached"].Refresh("cached"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the group of the subscription that was commited before is kept as is.
+	previous := NewDomainGroup("never", []string{"old.example.com"}, DefaultSinkhole)
+	if err := os.MkdirAll(filepath.Dir(hostsPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(hostsPath(), []byte(previous.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := c.SubscriptionGroups()
+	if !errors.Is(err, ErrPartialSync) {
+		t.Fatalf("SubscriptionGroups() error = %v, want %v", err, ErrPartialSync)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("SubscriptionGroups() = %v groups, want 2", len(groups))
+	}
+	if groups[0].group != "cached" || !slices.Equal(groups[0].Items(), testBlocklistDomains) {
+		t.Errorf("group of the cached subscription = %v %v", groups[0].group, groups[0].Items())
+	}
+	if !groups[1].Equal(previous) {
+		t.Errorf("group of the never refreshed subscription = %q, want %q", groups[1], previous)
+	}
+}
+
+func TestSubscriptionRefreshCacheFailure(t *testing.T) {
+	testRoot(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testBlocklist))
+	}))
+	defer srv.Close()
+
+	// the cache can't be written while its directory is a file.
+	if err := os.MkdirAll(filepath.Dir(SubscriptionsDir()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(SubscriptionsDir(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Subscription{Source: srv.URL}
+	if _, err := s.Refresh("ads"); err == nil {
+		t.Fatal("Refresh() without a cache succeeded")
+	}
+	if s.ETag != "" || s.Checksum != "" {
+		t.Fatalf("subscription %+v remembers the contents that failed to be cached", s)
+	}
+
+	if err := os.Remove(SubscriptionsDir()); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := s.Refresh("ads"); err != nil || !changed {
+		t.Fatalf("Refresh() = %v, %v, want the contents downloaded again", changed, err)
+	}
+	if domains, err := s.Domains("ads"); err != nil || !slices.Equal(domains, testBlocklistDomains) {
+		t.Errorf("Domains() = %v, %v, want %v", domains, err, testBlocklistDomains)
+	}
+}
+
+func TestSubscriptionShrunkRemoved(t *testing.T) {
+	previous := NewDomainGroup("ads", []string{"a.example.com", "b.example.com"}, DefaultSinkhole)
+
+	// a refreshed subscription keeps its key, dropping domains still removes it.
+	shrunk := Diff{Type: Domain, Delete: []Restriction{previous}, Missing: []Restriction{NewDomainGroup("ads", []string{"a.example.com"}, DefaultSinkhole)}}
+	if removed := shrunk.Removed(); len(removed) != 1 {
+		t.Errorf("Removed() of a shrunk subscription = %v, want it removed", removed)
+	}
+	grown := Diff{Type: Domain, Delete: []Restriction{previous}, Missing: []Restriction{NewDomainGroup("ads", []string{"a.example.com", "b.example.com", "c.example.com"}, DefaultSinkhole)}}
+	if removed := grown.Removed(); len(removed) != 0 {
+		t.Errorf("Removed() of a grown subscription = %v, want none", removed)
+	}
+}
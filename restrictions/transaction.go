@@ -0,0 +1,267 @@
+package restrictions
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// ErrRolledBack is returned when a commit failed and all
+// of its changes were reverted.
+var ErrRolledBack = errors.New("commit failed and was rolled back")
+
+// StepError is the failure to apply or revert a single restriction.
+type StepError struct {
+	Type Type
+	// Restriction is nil if the whole difference of
+	// the type failed to commit, see Kind.Commit.
+	Restriction Restriction
+	// Applying is set if the restriction failed to be
+	// applied, otherwise it failed to be reverted.
+	Applying bool
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	if e.Restriction == nil {
+		return fmt.Sprintf("failed to commit %s restrictions: %v", e.Type, e.Err)
+	}
+	action := "revert"
+	if e.Applying {
+		action = "apply"
+	}
+	return fmt.Sprintf("failed to %s %s restriction %s: %v", action, e.Type, label(e.Restriction), e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// StepErrors returns the failed steps reported by the error of Commit.
+func StepErrors(err error) []*StepError {
+	var steps []*StepError
+	var walk func(err error)
+	walk = func(err error) {
+		switch e := err.(type) {
+		case *StepError:
+			steps = append(steps, e)
+		case interface{ Unwrap() []error }:
+			for _, err := range e.Unwrap() {
+				walk(err)
+			}
+		case interface{ Unwrap() error }:
+			walk(e.Unwrap())
+		}
+	}
+	walk(err)
+	return steps
+}
+
+// PartialCommitError is returned by Commit when undoing a failed
+// commit failed too, it wraps ErrPartialCommit.
+type PartialCommitError struct {
+	Err error
+	// InEffect are the changes left in effect per type, the reverted
+	// restrictions that could not be applied back as Delete and the
+	// applied ones that could not be reverted as Missing.
+	InEffect []Diff
+}
+
+func (e *PartialCommitError) Error() string { return e.Err.Error() }
+
+func (e *PartialCommitError) Unwrap() error { return e.Err }
+
+// CommitOptions are the changes made along with the differences, see Commit.
+type CommitOptions struct {
+	// Backup backs up the state before the changes, see CreateBackup.
+	Backup bool
+	// Lock, if set, is stored along with the changes, see StoreLock.
+	Lock *Lock
+}
+
+// Commit commits the differences of all types as a single transaction.
+// The changes that need root, the backup, the difference of the
+// privileged kind and the lock, are made first and as a whole, see
+// Kind.Privileged. Without root that is a single request to the
+// privileged helper. Then the deleted restrictions of every other type
+// are reverted before its missing ones are applied, the whole
+// difference at once if the kind supports it, see Kind.Commit. If any
+// step fails, what it did is cleaned up as far as possible, the steps
+// done so far are undone in reverse order and ErrRolledBack is
+// returned. If undoing fails too, a PartialCommitError is returned.
+// Returns the backup, if created.
+func Commit(diffs []Diff, o CommitOptions, now time.Time) (*Backup, error) {
+	k, ok := privilegedKind()
+	if !ok {
+		return nil, errors.New("no privileged kind registered")
+	}
+	system := Diff{Type: k.Type}
+	var steps []step
+	for _, d := range diffs {
+		if d.Type == k.Type {
+			system = d
+			continue
+		}
+		steps = append(steps, diffSteps(d)...)
+	}
+
+	var b *Backup
+	if o.Backup || o.Lock != nil || len(system.Delete) > 0 || len(system.Missing) > 0 {
+		inverse := Diff{Type: k.Type, Missing: system.Delete, Delete: system.Missing}
+		first := step{
+			d: system,
+			do: func() (err error) {
+				b, err = requestCommit(system, o.Backup, o.Lock, now)
+				return err
+			},
+			undo: func() error { return k.Commit(inverse) },
+		}
+		steps = append([]step{first}, steps...)
+	}
+	return b, commitSteps(steps)
+}
+
+// step of a commit, see commitSteps.
+type step struct {
+	// d is the whole difference if commited at once,
+	// otherwise only the type of d is set.
+	d Diff
+	// r is nil if the step commits the whole difference.
+	r Restriction
+	// applied is set if the step applies the restriction,
+	// otherwise it reverts it.
+	applied  bool
+	do, undo func() error
+}
+
+// diffSteps returns the steps commiting the difference.
+func diffSteps(d Diff) []step {
+	if k, ok := KindOf(d.Type); ok && k.Commit != nil {
+		inverse := Diff{Type: d.Type, Missing: d.Delete, Delete: d.Missing}
+		return []step{{
+			d:    d,
+			do:   func() error { return k.Commit(d) },
+			undo: func() error { return k.Commit(inverse) },
+		}}
+	}
+	var steps []step
+	for _, r := range d.Delete {
+		steps = append(steps, step{d: Diff{Type: d.Type}, r: r, do: r.Revert, undo: r.Apply})
+	}
+	for _, r := range d.Missing {
+		steps = append(steps, step{d: Diff{Type: d.Type}, r: r, applied: true, do: r.Apply, undo: r.Revert})
+	}
+	return steps
+}
+
+// commitSteps runs the steps as a single transaction, see Commit.
+func commitSteps(steps []step) error {
+	var done []step
+	var cause error
+	for _, s := range steps {
+		if err := s.do(); err != nil {
+			cause = &StepError{Type: s.d.Type, Restriction: s.r, Applying: s.applied, Err: err}
+			// the failed step may have done some of its work, i.e. written the
+			// files of an application but failed to load it. Cleaning it up is
+			// best effort, as it is not known how far it got. The whole
+			// difference is commited either at once or not at all.
+			if s.r != nil {
+				s.undo()
+			}
+			break
+		}
+		done = append(done, s)
+	}
+	if cause == nil {
+		return nil
+	}
+
+	var errRollback error
+	inEffect := make(map[Type]*Diff)
+	var types []Type
+	for i := len(done) - 1; i >= 0; i-- {
+		s := done[i]
+		err := s.undo()
+		if err == nil {
+			continue
+		}
+		// undoing an applied restriction reverts it and vice versa.
+		errRollback = errors.Join(errRollback, &StepError{Type: s.d.Type, Restriction: s.r, Applying: !s.applied, Err: err})
+
+		d, ok := inEffect[s.d.Type]
+		if !ok {
+			d = &Diff{Type: s.d.Type}
+			inEffect[s.d.Type] = d
+			types = append(types, s.d.Type)
+		}
+		switch {
+		case s.r == nil:
+			d.Delete = append(d.Delete, s.d.Delete...)
+			d.Missing = append(d.Missing, s.d.Missing...)
+		case s.applied:
+			d.Missing = append(d.Missing, s.r)
+		default:
+			d.Delete = append(d.Delete, s.r)
+		}
+	}
+	if errRollback != nil {
+		partial := &PartialCommitError{Err: fmt.Errorf("%w: %w, rolling back failed too: %w", ErrPartialCommit, cause, errRollback)}
+		for _, t := range types {
+			partial.InEffect = append(partial.InEffect, *inEffect[t])
+		}
+		return partial
+	}
+	return fmt.Errorf("%w: %w", ErrRolledBack, cause)
+}
+
+// InEffect returns the items of the type of the difference that are in
+// effect once it is commited partially, see PartialCommitError, with
+// their schedules. These are the items commited previously whose
+// restriction was not deleted and the wanted items of c whose
+// restriction was applied.
+func (d *Diff) InEffect(c, previous *Config) (List, map[string]Schedule) {
+	k, ok := KindOf(d.Type)
+	if !ok {
+		return previous.Restrictions[d.Type], previous.Schedules[d.Type]
+	}
+	keys := func(restrictions []Restriction) map[string]bool {
+		m := make(map[string]bool)
+		for _, r := range restrictions {
+			m[r.Key()] = true
+		}
+		return m
+	}
+	deleted, applied := keys(d.Delete), keys(d.Missing)
+
+	var l List
+	schedules := make(map[string]Schedule)
+	for _, item := range previous.Restrictions[d.Type].Items() {
+		if !deleted[k.New(c, item).Key()] {
+			l = l.Append(item)
+			if s, ok := previous.Schedules[d.Type][item]; ok {
+				schedules[item] = s
+			}
+		}
+	}
+	for _, item := range c.Restrictions[d.Type].Items() {
+		if applied[k.New(c, item).Key()] && !slices.Contains(l.Items(), item) {
+			l = l.Append(item)
+			if s, ok := c.Schedules[d.Type][item]; ok {
+				schedules[item] = s
+			}
+		}
+	}
+	return l, schedules
+}
+
+// label names the restriction in errors by its first item.
+func label(r Restriction) string {
+	items := r.Items()
+	switch len(items) {
+	case 0:
+		return r.Key()
+	case 1:
+		return items[0]
+	default:
+		return fmt.Sprintf("%s and %v more", items[0], len(items)-1)
+	}
+}
@@ -0,0 +1,85 @@
+package restrictions
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testType is a type without a registered kind.
+const testType Type = 250
+
+// fakeRestriction records its calls and fails as configured.
+type fakeRestriction struct {
+	key                   string
+	failApply, failRevert bool
+	calls                 *[]string
+}
+
+func (f fakeRestriction) Key() string                  { return f.key }
+func (f fakeRestriction) Equal(other Restriction) bool { return other.Key() == f.key }
+func (f fakeRestriction) Describe() string             { return f.key }
+func (f fakeRestriction) Items() []string              { return []string{f.key} }
+
+func (f fakeRestriction) Apply() error {
+	*f.calls = append(*f.calls, "apply "+f.key)
+	if f.failApply {
+		return errors.New("apply failed")
+	}
+	return nil
+}
+
+func (f fakeRestriction) Revert() error {
+	*f.calls = append(*f.calls, "revert "+f.key)
+	if f.failRevert {
+		return errors.New("revert failed")
+	}
+	return nil
+}
+
+func TestCommitRollsBackDoneSteps(t *testing.T) {
+	var calls []string
+	a := fakeRestriction{key: "a", calls: &calls}
+	b := fakeRestriction{key: "b", calls: &calls}
+	c := fakeRestriction{key: "c", failApply: true, calls: &calls}
+
+	_, err := Commit([]Diff{{Type: testType, Delete: []Restriction{a}, Missing: []Restriction{b, c}}}, CommitOptions{}, time.Now())
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Commit() error = %v, want %v", err, ErrRolledBack)
+	}
+	// the failed step is cleaned up first, only the done steps are undone.
+	want := []string{"revert a", "apply b", "apply c", "revert c", "revert b", "apply a"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("Commit() calls = %v, want %v", calls, want)
+	}
+	if steps := StepErrors(err); len(steps) != 1 || steps[0].Restriction.Key() != "c" || !steps[0].Applying {
+		t.Errorf("StepErrors() = %v, want the apply of c", steps)
+	}
+}
+
+func TestCommitReportsInEffect(t *testing.T) {
+	var calls []string
+	a := fakeRestriction{key: "a", calls: &calls}
+	b := fakeRestriction{key: "b", failRevert: true, calls: &calls}
+	// failing to clean up the failed step is not a failed rollback.
+	c := fakeRestriction{key: "c", failApply: true, failRevert: true, calls: &calls}
+
+	_, err := Commit([]Diff{{Type: testType, Delete: []Restriction{a}, Missing: []Restriction{b, c}}}, CommitOptions{}, time.Now())
+	var partial *PartialCommitError
+	if !errors.As(err, &partial) || !errors.Is(err, ErrPartialCommit) {
+		t.Fatalf("Commit() error = %v, want a %T", err, partial)
+	}
+	if len(partial.InEffect) != 1 {
+		t.Fatalf("InEffect = %v, want the changes of a single type", partial.InEffect)
+	}
+	d := partial.InEffect[0]
+	if d.Type != testType || len(d.Delete) != 0 || len(d.Missing) != 1 || d.Missing[0].Key() != "b" {
+		t.Errorf("InEffect = %+v, want b applied", d)
+	}
+
+	steps := StepErrors(err)
+	if len(steps) != 2 || steps[0].Restriction.Key() != "c" || steps[1].Restriction.Key() != "b" || steps[1].Applying {
+		t.Errorf("StepErrors() = %v, want the apply of c and the revert of b", steps)
+	}
+}
@@ -4,21 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"slices"
 	"strings"
+	"time"
 )
 
-var home = ""
-
-func init() {
-	h, err := os.UserHomeDir()
-	if err != nil {
-		panic(err)
-	}
-	home = h
-}
-
 var (
 	// ErrPartialSync is returned when not all items could be checked
 	// for a 100% synchronization.
@@ -37,9 +27,8 @@ const (
 	DndApplicationPrefix = "com.dnd."
 )
 
-// Type of a BlockedItem.
-//
-//go:generate stringer -type=Type
+// Type of a BlockedItem. The types are stored in the config
+// and must not be renumbered, every type registers its Kind.
 type Type uint8
 
 const (
@@ -49,148 +38,220 @@ const (
 	// Represents application installed on the system that should
 	// not be able to run.
 	Application
-	TypeEnd
 )
 
-var TypeFromString = map[string]Type{
-	"Domain":      Domain,
-	"Application": Application,
+// Restriction is a single restriction enforced at the OS level.
+type Restriction interface {
+	// Key identifies the restriction regardless of its contents.
+	// An updated restriction has the same key as the one it replaces.
+	Key() string
+	// Equal reports whether both restrictions are identical.
+	Equal(Restriction) bool
+	// Describe returns a human readable description, one line per entry.
+	Describe() string
+	// Items returns the items enforced by the restriction.
+	Items() []string
+	// Apply enforces the restriction at the OS level.
+	Apply() error
+	// Revert removes the restriction from the OS level.
+	Revert() error
+}
+
+func toRestrictions[T Restriction](s []T) []Restriction {
+	out := make([]Restriction, 0, len(s))
+	for _, r := range s {
+		out = append(out, r)
+	}
+	return out
 }
 
 type Diff struct {
 	Type    Type
-	Matched []any
-	Missing []any
-	Delete  []any
+	Matched []Restriction
+	Missing []Restriction
+	Delete  []Restriction
+
+	// Scheduled lists the restrictions with a schedule
+	// at the time the diff was determined.
+	Scheduled []Scheduled
 }
 
-func (t Type) Diff(l List) (Diff, error) {
-	var diff Diff
-	var err error
+// Scheduled is a restriction that is only in effect during the
+// time windows of its schedule.
+type Scheduled struct {
+	Item     string
+	Schedule Schedule
+	Active   bool
+}
 
-	switch t {
-	case Domain:
-		var actual []RDomain
-		if actual, err = SyncDomains(); err != nil {
-			if !errors.Is(err, ErrPartialSync) {
-				return Diff{}, fmt.Errorf("failed to synchronize actual state of domains: %w", err)
-			}
+// Diff determines the difference between the restrictions of the
+// profile in use that are in effect at the given time and the actual
+// state of the OS.
+func (c *Config) Diff(t Type, now time.Time) (Diff, error) {
+	var extra []Restriction
+	var errExtra error
+	if k, ok := KindOf(t); ok && k.Extra != nil {
+		if extra, errExtra = k.Extra(c); errExtra != nil && !errors.Is(errExtra, ErrPartialSync) {
+			return Diff{Type: t}, errExtra
 		}
-		diff = diffDomain(actual, l)
-	case Application:
-		var actual []RApplication
-		if actual, err = SyncApplications(); err != nil {
-			if !errors.Is(err, ErrPartialSync) {
-				return Diff{}, fmt.Errorf("failed to synchronize actula state of application restrictions: %w", err)
-			}
-		}
-		diff = diffApplication(actual, l)
 	}
 
+	diff, err := t.Diff(c, c.Active(now)[t], extra...)
+	diff.Scheduled = c.Scheduled(t, now)
+	if errExtra != nil {
+		err = errors.Join(err, errExtra)
+	}
 	return diff, err // can be partial error
 }
 
-func (d *Diff) Print(w io.Writer) {
-	builder := strings.Builder{}
-	if d.Type == Domain {
-		builder.WriteString("Domains:\n")
-		builder.WriteString(fmt.Sprintf("~ matched [%v]\n", len(d.Matched)))
-		for _, m := range d.Matched {
-			for _, r := range m.(RDomain).Restrictions {
-				builder.WriteString(fmt.Sprintf("\tIP:%s\tDomains:%v\n", r.IP, r.Domains))
-			}
-		}
-		builder.WriteString(fmt.Sprintf("+ add [%v]\n", len(d.Missing)))
-		for _, m := range d.Missing {
-			for _, r := range m.(RDomain).Restrictions {
-				builder.WriteString(fmt.Sprintf("\tIP:%s\tDomains:%v\n", r.IP, r.Domains))
-			}
-		}
-		builder.WriteString(fmt.Sprintf("- delete [%v]\n", len(d.Delete)))
-		for _, m := range d.Delete {
-			for _, r := range m.(RDomain).Restrictions {
-				builder.WriteString(fmt.Sprintf("\tIP:%s\tDomains:%v\n", r.IP, r.Domains))
-			}
-		}
+// Diff determines the difference between the wanted restrictions and
+// the actual state of the OS. The restrictions are created for the
+// items of the list with the settings of the config, the extra
+// restrictions are wanted in addition to them.
+func (t Type) Diff(c *Config, l List, extra ...Restriction) (Diff, error) {
+	k, ok := KindOf(t)
+	if !ok {
+		return Diff{Type: t}, fmt.Errorf("unsupported restriction type %s", t)
 	}
-	if d.Type == Application {
-		builder.WriteString("Applications\n")
-		builder.WriteString(fmt.Sprintf("~ matched [%v]\n", len(d.Matched)))
-		for _, m := range d.Matched {
-			builder.WriteString(fmt.Sprintf("\tPattern:%v\n", m.(RApplication).Pattern))
+
+	actual, err := k.Sync()
+	if err != nil {
+		if !errors.Is(err, ErrPartialSync) {
+			return Diff{Type: t}, fmt.Errorf("failed to synchronize actual state of %s restrictions: %w", t, err)
 		}
-		builder.WriteString(fmt.Sprintf("+ add [%v]\n", len(d.Missing)))
-		for _, m := range d.Missing {
-			builder.WriteString(fmt.Sprintf("\tPattern:%v\n", m.(RApplication).Pattern))
+	}
+
+	var wanted []Restriction
+	for _, item := range l.Items() {
+		wanted = append(wanted, k.New(c, item))
+	}
+	wanted = append(wanted, extra...)
+
+	diff := Diff{Type: t}
+	for _, w := range wanted {
+		if slices.ContainsFunc(actual, w.Equal) {
+			diff.Matched = append(diff.Matched, w)
+		} else {
+			diff.Missing = append(diff.Missing, w)
 		}
-		builder.WriteString(fmt.Sprintf("- delete [%v]\n", len(d.Delete)))
-		for _, m := range d.Delete {
-			builder.WriteString(fmt.Sprintf("\tPattern:%v\n", m.(RApplication).Pattern))
+	}
+	for _, r := range actual {
+		if !slices.ContainsFunc(wanted, r.Equal) {
+			diff.Delete = append(diff.Delete, r)
 		}
 	}
-	fmt.Fprintln(w, builder.String())
+
+	return diff, err // can be partial error
 }
 
-func (d *Diff) Commit() error {
-	if d.Type == Domain {
-		if err := d.domainCommit(); err != nil {
-			return err
+func (d *Diff) Print(w io.Writer) {
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("%ss:\n", d.Type))
+	builder.WriteString(fmt.Sprintf("~ matched [%v]\n", len(d.Matched)))
+	writeRestrictions(&builder, d.Matched)
+	builder.WriteString(fmt.Sprintf("+ add [%v]\n", len(d.Missing)))
+	writeRestrictions(&builder, d.Missing)
+	builder.WriteString(fmt.Sprintf("- delete [%v]\n", len(d.Delete)))
+	writeRestrictions(&builder, d.Delete)
+	if upgraded := len(d.Delete) - len(d.Removed()); upgraded > 0 {
+		builder.WriteString(fmt.Sprintf("^ upgrade [%v] deleted restrictions are added back with updated contents\n", upgraded))
+	}
+	if len(d.Scheduled) > 0 {
+		builder.WriteString(fmt.Sprintf("@ scheduled [%v]\n", len(d.Scheduled)))
+		for _, s := range d.Scheduled {
+			status := "inactive"
+			if s.Active {
+				status = "active"
+			}
+			builder.WriteString(fmt.Sprintf("\t%s\t%s\t%s\n", s.Item, s.Schedule, status))
 		}
 	}
+	fmt.Fprintln(w, builder.String())
+}
 
-	if d.Type == Application {
-		if err := d.applicationCommit(); err != nil {
-			return err
+func writeRestrictions(b *strings.Builder, restrictions []Restriction) {
+	for _, r := range restrictions {
+		for _, line := range strings.Split(strings.TrimSuffix(r.Describe(), "\n"), "\n") {
+			b.WriteString("\t" + line + "\n")
 		}
 	}
-	return nil
 }
 
-func diffDomain(actual []RDomain, wanted List) Diff {
-	diff := Diff{
-		Type: Domain,
+// Commit reverts the deleted restrictions and applies the
+// missing ones. Returns ErrPartialCommit if only some of
+// them succeeded.
+func (d *Diff) Commit() error {
+	if k, ok := KindOf(d.Type); ok && k.Commit != nil {
+		return k.Commit(*d)
 	}
 
-	var wr []RDomain
-	for _, r := range wanted.Items() {
-		wr = append(wr, NewDomain(r))
-		contains := slices.ContainsFunc(actual, func(dr RDomain) bool { return dr.Equal(wr[len(wr)-1]) })
-		if contains {
-			diff.Matched = append(diff.Matched, wr[len(wr)-1])
-		} else {
-			diff.Missing = append(diff.Missing, wr[len(wr)-1])
+	var errCommited error
+	var commited int
+
+	for _, r := range d.Delete {
+		if err := r.Revert(); err != nil {
+			errCommited = errors.Join(errCommited, err)
+			continue
 		}
+		commited++
 	}
 
-	for _, r := range actual {
-		if !slices.ContainsFunc(wr, func(dr RDomain) bool { return dr.Equal(r) }) {
-			diff.Delete = append(diff.Delete, r)
+	for _, r := range d.Missing {
+		if err := r.Apply(); err != nil {
+			errCommited = errors.Join(errCommited, err)
+			continue
 		}
+		commited++
 	}
 
-	return diff
+	if errCommited != nil && commited > 0 {
+		errCommited = fmt.Errorf("%w:%w", ErrPartialCommit, errCommited)
+	}
+
+	return errCommited
 }
 
-func diffApplication(actual []RApplication, wanted List) Diff {
-	diff := Diff{
-		Type: Application,
+// Removed returns the restrictions that are deleted without being
+// replaced by an updated version of themselves enforcing at least as
+// much. For example, a domain block that only contains the IPv4
+// sinkhole is deleted and added back with the IPv6 sinkhole as well,
+// but it is not removed. Pointed to another sinkhole, or with fewer
+// domains, it is.
+func (d *Diff) Removed() []Restriction {
+	var removed []Restriction
+	for _, del := range d.Delete {
+		replaced := slices.ContainsFunc(d.Missing, func(m Restriction) bool { return m.Key() == del.Key() && covers(m, del) })
+		if !replaced {
+			removed = append(removed, del)
+		}
 	}
+	return removed
+}
 
-	var wr []RApplication
-	for _, r := range wanted.Items() {
-		wr = append(wr, NewApplication(r))
-		if slices.Contains(actual, wr[len(wr)-1]) {
-			diff.Matched = append(diff.Matched, wr[len(wr)-1])
-		} else {
-			diff.Missing = append(diff.Missing, wr[len(wr)-1])
+// covers reports whether r enforces at least what other does. Unless
+// r tells otherwise, see RDomain.Covers, that is all of its items.
+func covers(r, other Restriction) bool {
+	if c, ok := r.(interface{ Covers(Restriction) bool }); ok {
+		return c.Covers(other)
+	}
+	items := r.Items()
+	for _, item := range other.Items() {
+		if !slices.Contains(items, item) {
+			return false
 		}
 	}
+	return true
+}
 
-	for _, r := range actual {
-		if !slices.Contains(wr, r) {
-			diff.Delete = append(diff.Delete, r)
+// Repair returns the difference restoring the wanted restrictions
+// that are missing or were modified, without deleting any of the
+// restrictions that are not wanted.
+func (d *Diff) Repair() Diff {
+	repair := Diff{Type: d.Type, Missing: d.Missing}
+	for _, del := range d.Delete {
+		if slices.ContainsFunc(d.Missing, func(m Restriction) bool { return m.Key() == del.Key() }) {
+			repair.Delete = append(repair.Delete, del)
 		}
 	}
-
-	return diff
+	return repair
 }
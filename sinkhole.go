@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+func setSinkhole(w io.Writer, args ...string) {
+	_, opts, err := parseFlags(args, "ipv4", "ipv6")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	if !opts.Has("ipv4") && !opts.Has("ipv6") {
+		current := c.Sinkhole.OrDefault()
+		fmt.Fprintf(w, "IPv4: %s\nIPv6: %s\n", current.IPv4, current.IPv6)
+		return
+	}
+
+	s := restrictions.Sinkhole{}
+	if c.Sinkhole != nil {
+		s = *c.Sinkhole
+	}
+
+	if opts.Has("ipv4") {
+		s.IPv4 = opts["ipv4"]
+	}
+	if opts.Has("ipv6") {
+		s.IPv6 = opts["ipv6"]
+	}
+	if err := restrictions.ValidateSinkhole(s); err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	// pointing the blocked domains elsewhere lifts the restrictions.
+	if s.OrDefault() != c.Sinkhole.OrDefault() {
+		if err := restrictions.CheckLock(c, time.Now()); err != nil {
+			fmt.Fprintf(w, "%v, refusing to change the sinkhole\n", err)
+			return
+		}
+	}
+
+	c.Sinkhole = &s
+	if err := restrictions.WriteConfig(c); err != nil {
+		fmt.Fprintf(w, "failed to update config: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "sinkhole updated, commit to migrate the domain restrictions\n")
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+// States of a drifted item reported by status.
+const (
+	// the item was added to the config but not commited yet.
+	statePendingAdd = "pending-add"
+	// the item was deleted from the config but not commited yet.
+	statePendingDelete = "pending-delete"
+	// the schedule of the item changed but was not commited yet.
+	statePendingSchedule = "pending-schedule"
+	// a setting, i.e. the sinkhole, changed but was not commited yet.
+	statePendingSetting = "pending-setting"
+	// a commited restriction was removed outside of dnd.
+	stateMissing = "missing"
+	// a commited restriction was modified outside of dnd.
+	stateTampered = "tampered"
+	// a restriction managed by dnd is not part of the last commit.
+	stateForeign = "foreign"
+	// the item failed at the last commit.
+	stateFailed = "failed"
+)
+
+// driftOutput is a single item that differs between the config,
+// the last commit and the actual state of the OS.
+type driftOutput struct {
+	Type  string `json:"type" yaml:"type"`
+	State string `json:"state" yaml:"state"`
+	// Key identifies the restriction at the OS level, only
+	// present for the items found on the OS.
+	Key   string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Items []string `json:"items" yaml:"items"`
+	// Error is the reason a failed item failed.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// statusOutput is the output of `dnd status`.
+type statusOutput struct {
+	SchemaVersion int    `json:"schema_version" yaml:"schema_version"`
+	Profile       string `json:"profile" yaml:"profile"`
+	// Version of the config and of the last commit,
+	// the latter is absent if never commited.
+	Version         int64  `json:"version" yaml:"version"`
+	CommitedVersion *int64 `json:"commited_version,omitempty" yaml:"commited_version,omitempty"`
+	// InSync reports whether the config, the last commit
+	// and the actual state of the OS all agree.
+	InSync bool          `json:"in_sync" yaml:"in_sync"`
+	Drift  []driftOutput `json:"drift" yaml:"drift"`
+	// Messages are the warnings and errors encountered.
+	Messages []string `json:"messages" yaml:"messages"`
+}
+
+func (s statusOutput) table(w io.Writer) {
+	fmt.Fprintf(w, "TYPE\tSTATE\tITEMS\n")
+	for _, d := range s.Drift {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", d.Type, d.State, summarize(d.Items))
+	}
+	for _, m := range s.Messages {
+		fmt.Fprintf(w, "-\tmessage\t%s\n", m)
+	}
+}
+
+func (s statusOutput) print(w io.Writer) {
+	fmt.Fprintf(w, "profile %q", s.Profile)
+	if s.CommitedVersion == nil {
+		fmt.Fprintf(w, ", never commited")
+	}
+	fmt.Fprintln(w)
+
+	if s.InSync {
+		fmt.Fprintf(w, "in sync\n")
+	}
+
+	sections := []struct {
+		title  string
+		states []string
+	}{
+		{"changes not commited yet (dnd commit):", []string{statePendingAdd, statePendingDelete, statePendingSchedule, statePendingSetting}},
+		{"commited restrictions changed outside of dnd (dnd commit to repair):", []string{stateMissing, stateTampered}},
+		{"restrictions not known to the last commit:", []string{stateForeign}},
+		{"restrictions that failed at the last commit (dnd commit to retry):", []string{stateFailed}},
+	}
+	for _, section := range sections {
+		var drift []driftOutput
+		for _, d := range s.Drift {
+			if slices.Contains(section.states, d.State) {
+				drift = append(drift, d)
+			}
+		}
+		if len(drift) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s\n", section.title)
+		for _, d := range drift {
+			fmt.Fprintf(w, "\t%-16s %-11s %s", d.State, d.Type, summarize(d.Items))
+			if d.Error != "" {
+				fmt.Fprintf(w, ": %s", d.Error)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	for _, m := range s.Messages {
+		fmt.Fprintf(w, "%s\n", m)
+	}
+}
+
+// pendingDrift returns the changes of the config not yet commited.
+func pendingDrift(c *restrictions.Config) []driftOutput { return changes(c.LastCommited, c) }
+
+// changes returns the changes of the restrictions of the profile
+// in use and of the settings between the configs.
+func changes(from, to *restrictions.Config) []driftOutput {
+	if from == nil {
+		from = &restrictions.Config{}
+	}
+
+	var drift []driftOutput
+	for _, k := range restrictions.Kinds() {
+		configured := to.Restrictions[k.Type].Items()
+		previous := from.Restrictions[k.Type].Items()
+		for _, item := range configured {
+			switch {
+			case !slices.Contains(previous, item):
+				drift = append(drift, driftOutput{Type: typeName(k.Type), State: statePendingAdd, Items: []string{item}})
+			case to.Schedules[k.Type][item] != from.Schedules[k.Type][item]:
+				drift = append(drift, driftOutput{Type: typeName(k.Type), State: statePendingSchedule, Items: []string{item}})
+			}
+		}
+		for _, item := range previous {
+			if !slices.Contains(configured, item) {
+				drift = append(drift, driftOutput{Type: typeName(k.Type), State: statePendingDelete, Items: []string{item}})
+			}
+		}
+	}
+
+	if to.Sinkhole.OrDefault() != from.Sinkhole.OrDefault() {
+		drift = append(drift, driftOutput{Type: typeName(restrictions.Domain), State: statePendingSetting, Items: []string{"sinkhole"}})
+	}
+	if !slices.Equal(to.SubscriptionNames(), from.SubscriptionNames()) {
+		drift = append(drift, driftOutput{Type: typeName(restrictions.Domain), State: statePendingSetting, Items: []string{"subscriptions"}})
+	}
+	return drift
+}
+
+// actualDrift compares the last commit with the actual state of the OS.
+func actualDrift(c *restrictions.Config, t restrictions.Type, now time.Time) ([]driftOutput, error) {
+	commited := c.LastCommited
+	if commited == nil {
+		commited = &restrictions.Config{}
+	}
+
+	diff, err := commited.Diff(t, now)
+	if err != nil && !errors.Is(err, restrictions.ErrPartialSync) {
+		return nil, err
+	}
+
+	var drift []driftOutput
+	tampered := make(map[string]bool)
+	for _, r := range diff.Missing {
+		state := stateMissing
+		if slices.ContainsFunc(diff.Delete, func(d restrictions.Restriction) bool { return d.Key() == r.Key() }) {
+			state = stateTampered
+			tampered[r.Key()] = true
+		}
+		drift = append(drift, driftOutput{Type: typeName(t), State: state, Key: strings.TrimSpace(r.Key()), Items: r.Items()})
+	}
+	for _, r := range diff.Delete {
+		if tampered[r.Key()] {
+			continue
+		}
+		drift = append(drift, driftOutput{Type: typeName(t), State: stateForeign, Key: strings.TrimSpace(r.Key()), Items: r.Items()})
+	}
+	return drift, err // can be partial error
+}
+
+// status reports the drift between the config, the last
+// commit and the actual state of the OS. Returns the exit status.
+func status(w io.Writer, args ...string) int {
+	_, opts, err := parseFlags(args, "output")
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return exitFailure
+	}
+	format, err := parseOutput(opts)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return exitFailure
+	}
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return exitFailure
+		}
+		c = &restrictions.Config{}
+	}
+
+	now := time.Now()
+	out := statusOutput{
+		SchemaVersion: schemaVersion,
+		Profile:       c.CurrentProfile(),
+		Version:       c.Version,
+		Drift:         pendingDrift(c),
+		Messages:      []string{},
+	}
+	if c.LastCommited != nil {
+		out.CommitedVersion = &c.LastCommited.Version
+	}
+
+	code := exitOK
+	for _, k := range restrictions.Kinds() {
+		drift, err := actualDrift(c, k.Type, now)
+		if err != nil {
+			if !errors.Is(err, restrictions.ErrPartialSync) {
+				out.Messages = append(out.Messages, fmt.Sprintf("failed to determine the actual state of %s restrictions: %v", k.Type, err))
+				code = exitFailure
+				continue
+			}
+			out.Messages = append(out.Messages, fmt.Sprintf("partially synced actual state of %s restrictions: %v", k.Type, err))
+		}
+		out.Drift = append(out.Drift, drift...)
+	}
+	for _, f := range failedItems(c) {
+		out.Drift = append(out.Drift, driftOutput{Type: f.Type, State: stateFailed, Items: []string{f.Item}, Error: f.Error})
+	}
+	if out.Drift == nil {
+		out.Drift = []driftOutput{}
+	}
+	out.InSync = len(out.Drift) == 0 && code == exitOK
+
+	if format == "" {
+		out.print(w)
+	} else if err := encode(w, format, out); err != nil {
+		fmt.Fprintf(w, "failed to encode output: %v\n", err)
+		return exitFailure
+	}
+
+	if code == exitOK && len(out.Drift) > 0 {
+		code = exitPending
+	}
+	return code
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Despire/dnd/restrictions"
+)
+
+func subscribe(w io.Writer, args ...string) {
+	args, opts, err := parseFlags(args)
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+
+	// the blocklists are downloaded before locking the config, which
+	// is only locked to write the refreshed subscriptions.
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+
+	refreshed := make(map[string]*restrictions.Subscription)
+	switch {
+	case opts.Has("refresh"):
+		names := args
+		if len(names) == 0 {
+			names = c.SubscriptionNames()
+		}
+		for _, name := range names {
+			s, ok := c.Subscriptions[name]
+			if !ok {
+				fmt.Fprintf(w, "subscription %s not found\n", name)
+				continue
+			}
+			changed, err := s.Refresh(name)
+			if err != nil {
+				fmt.Fprintf(w, "%v, keeping the cached copy\n", err)
+				continue
+			}
+			refreshed[name] = s
+			fmt.Fprintf(w, "refreshed subscription %s, changed: %v\n", name, changed)
+		}
+	case len(args) == 0:
+		for _, name := range c.SubscriptionNames() {
+			s := c.Subscriptions[name]
+			domains, err := s.Domains(name)
+			if err != nil {
+				fmt.Fprintf(w, "%s\t%s\t%v\n", name, s.Source, err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%v domains\trefreshed %s\n", name, s.Source, len(domains), s.Refreshed.Format(time.DateTime))
+		}
+		return
+	case len(args) < 2:
+		fmt.Fprintf(w, "no <url-or-path> specified\n")
+		return
+	default:
+		name, source := args[0], args[1]
+		if err := restrictions.ValidateSubscriptionName(name); err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		if _, ok := c.Subscriptions[name]; ok {
+			fmt.Fprintf(w, "subscription %s already exists\n", name)
+			return
+		}
+		s := &restrictions.Subscription{Source: source}
+		if _, err := s.Refresh(name); err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		domains, err := s.Domains(name)
+		if err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			return
+		}
+		refreshed[name] = s
+		fmt.Fprintf(w, "subscribed to %s with %v domains\n", name, len(domains))
+	}
+	if len(refreshed) == 0 {
+		return
+	}
+
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
+	c, err = restrictions.ReadConfig()
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+			return
+		}
+		c = &restrictions.Config{}
+	}
+	for name, s := range refreshed {
+		_, ok := c.Subscriptions[name]
+		switch {
+		case opts.Has("refresh") && !ok:
+			fmt.Fprintf(w, "subscription %s was removed in the meantime\n", name)
+			continue
+		case !opts.Has("refresh") && ok:
+			fmt.Fprintf(w, "subscription %s was added in the meantime\n", name)
+			return
+		}
+		if c.Subscriptions == nil {
+			c.Subscriptions = make(map[string]*restrictions.Subscription)
+		}
+		c.Subscriptions[name] = s
+	}
+
+	if err := restrictions.WriteConfig(c); err != nil {
+		fmt.Fprintf(w, "failed to update config: %v\n", err)
+		return
+	}
+}
+
+func unsubscribe(w io.Writer, args ...string) {
+	if len(args) < 1 {
+		fmt.Fprintf(w, "no <name> specified\n")
+		return
+	}
+
+	unlock, err := restrictions.LockConfig()
+	if err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+		return
+	}
+	defer unlock()
+
+	c, err := restrictions.ReadConfig()
+	if err != nil {
+		fmt.Fprintf(w, "failed to read config %s: %v\n", restrictions.ConfigPath(), err)
+		return
+	}
+
+	if err := restrictions.CheckLock(c, time.Now()); err != nil {
+		fmt.Fprintf(w, "%v, refusing to delete\n", err)
+		return
+	}
+
+	if _, ok := c.Subscriptions[args[0]]; !ok {
+		fmt.Fprintf(w, "subscription %s not found\n", args[0])
+		return
+	}
+	delete(c.Subscriptions, args[0])
+
+	if err := restrictions.WriteConfig(c); err != nil {
+		fmt.Fprintf(w, "failed to update config: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "unsubscribed from %s, commit to remove its restrictions\n", args[0])
+}
+
+// refreshSubscriptions refreshes the subscriptions whose cached
+// copy is older than restrictions.SubscriptionMaxAge. On failure
+// the cached copy is used.
+func refreshSubscriptions(w io.Writer, c *restrictions.Config, now time.Time) {
+	for _, name := range c.SubscriptionNames() {
+		s := c.Subscriptions[name]
+		if now.Sub(s.Refreshed) < restrictions.SubscriptionMaxAge {
+			continue
+		}
+		if _, err := s.Refresh(name); err != nil {
+			fmt.Fprintf(w, "%v, using the cached copy\n", err)
+		}
+	}
+}